@@ -9,18 +9,19 @@ import (
 )
 
 type Config struct {
-	App         AppConfig         `yaml:"app"`
-	Audio       AudioConfig       `yaml:"audio"`
-	WakeWord    WakeWordConfig    `yaml:"wake_word"`
-	STT         STTConfig         `yaml:"stt"`
-	TTS         TTSConfig         `yaml:"tts"`
-	Context     ContextConfig     `yaml:"context"`
-	Retrieval   RetrievalConfig   `yaml:"retrieval"`
-	LLM         LLMConfig         `yaml:"llm"`
-	Prompts     PromptsConfig     `yaml:"prompts"`
-	Performance PerformanceConfig `yaml:"performance"`
-	GRPC        GRPCConfig        `yaml:"grpc"`
-	Languages   LanguagesConfig   `yaml:"languages"`
+	App          AppConfig          `yaml:"app"`
+	Audio        AudioConfig        `yaml:"audio"`
+	WakeWord     WakeWordConfig     `yaml:"wake_word"`
+	Conversation ConversationConfig `yaml:"conversation"`
+	STT          STTConfig          `yaml:"stt"`
+	TTS          TTSConfig          `yaml:"tts"`
+	Context      ContextConfig      `yaml:"context"`
+	Retrieval    RetrievalConfig    `yaml:"retrieval"`
+	LLM          LLMConfig          `yaml:"llm"`
+	Prompts      PromptsConfig      `yaml:"prompts"`
+	Performance  PerformanceConfig  `yaml:"performance"`
+	GRPC         GRPCConfig         `yaml:"grpc"`
+	Languages    LanguagesConfig    `yaml:"languages"`
 }
 
 type AppConfig struct {
@@ -30,18 +31,80 @@ type AppConfig struct {
 }
 
 type AudioConfig struct {
-	SampleRate int    `yaml:"sample_rate"`
-	Channels   int    `yaml:"channels"`
-	BitDepth   int    `yaml:"bit_depth"`
-	BufferSize int    `yaml:"buffer_size"`
-	DeviceName string `yaml:"device_name"`
+	SampleRate int       `yaml:"sample_rate"`
+	Channels   int       `yaml:"channels"`
+	BitDepth   int       `yaml:"bit_depth"`
+	BufferSize int       `yaml:"buffer_size"`
+	DeviceName string    `yaml:"device_name"`
+	VAD        VADConfig `yaml:"vad"`
+}
+
+// VADConfig controls how the audio stream is cut into utterances
+type VADConfig struct {
+	FrameMs int `yaml:"frame_ms"`
+	// A frame is speech when its energy is EnergyMarginDB above the tracked
+	// noise floor and above MinEnergyDB, and its zero-crossing rate is at
+	// most MaxZeroCrossingRate (broadband noise crosses zero far more often
+	// than voiced speech)
+	EnergyMarginDB      float64 `yaml:"energy_margin_db"`
+	MinEnergyDB         float64 `yaml:"min_energy_db"`
+	MaxZeroCrossingRate float64 `yaml:"max_zero_crossing_rate"`
+	// Aggressiveness 0-3 trades missed speech for fewer false starts, like WebRTC VAD modes
+	Aggressiveness      int `yaml:"aggressiveness"`
+	StartMs             int `yaml:"start_ms"`    // speech needed to open an utterance
+	PreRollMs           int `yaml:"pre_roll_ms"` // audio kept from before the start
+	HangoverMs          int `yaml:"hangover_ms"` // silence that ends an utterance
+	MinUtteranceMs      int `yaml:"min_utterance_ms"`
+	MaxUtteranceSeconds int `yaml:"max_utterance_seconds"`
 }
 
 type WakeWordConfig struct {
-	Enabled       bool   `yaml:"enabled"`
-	Word          string `yaml:"word"`
-	CaseSensitive bool   `yaml:"case_sensitive"`
-	MatchType     string `yaml:"match_type"`
+	Enabled bool   `yaml:"enabled"`
+	Word    string `yaml:"word"`
+	// Phrases are more wake phrases by language code, checked along with
+	// Word when STT reports that language
+	Phrases       map[string][]string `yaml:"phrases"`
+	CaseSensitive bool                `yaml:"case_sensitive"`
+	MatchType     string              `yaml:"match_type"`
+	// Fuzzy accepts near misses such as "Sur" for "Sir"
+	Fuzzy           bool `yaml:"fuzzy"`
+	MaxEditDistance int  `yaml:"max_edit_distance"` // per word; 0 picks by word length
+	// Acoustic spots the wake word in raw audio before transcription, so
+	// STT only runs on utterances that start with it
+	Acoustic AcousticWakeWordConfig `yaml:"acoustic"`
+}
+
+type AcousticWakeWordConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Templates is a directory of WAV recordings of the wake word, recorded
+	// at audio.sample_rate
+	Templates string `yaml:"templates"`
+	// Threshold is the largest average MFCC distance that counts as a match;
+	// lower is stricter
+	Threshold float64 `yaml:"threshold"`
+}
+
+// ConversationConfig controls follow-up questions after an answer
+type ConversationConfig struct {
+	// FollowUpSeconds is how long after an answer the next utterance is
+	// taken as a follow-up without the wake word; 0 turns follow-ups off
+	FollowUpSeconds int `yaml:"follow_up_seconds"`
+	// StopPhrases end the conversation when said on their own
+	StopPhrases []string `yaml:"stop_phrases"`
+	// HistoryPath is the bbolt file conversations are kept in across
+	// restarts; empty keeps them in memory only
+	HistoryPath string `yaml:"history_path"`
+	// HistoryTokens bounds the earlier turns sent with each question; older
+	// turns are summarized to fit
+	HistoryTokens int `yaml:"history_tokens"`
+	// KeepTurns is how many recent question/answer pairs are always kept
+	// word for word when older ones are summarized
+	KeepTurns int `yaml:"keep_turns"`
+	// SessionTimeoutMinutes starts a new conversation after this long
+	// without a question
+	SessionTimeoutMinutes int `yaml:"session_timeout_minutes"`
+	// HistoryRetentionDays deletes saved conversations idle for longer
+	HistoryRetentionDays int `yaml:"history_retention_days"`
 }
 
 type STTConfig struct {
@@ -51,6 +114,18 @@ type STTConfig struct {
 	Threads            int    `yaml:"threads"`
 	Translate          bool   `yaml:"translate"`
 	MaxDurationSeconds int    `yaml:"max_duration_seconds"`
+	Decoder            string `yaml:"decoder"` // ffmpeg binary used to decode compressed audio files
+	Command            string `yaml:"command"` // whisper.cpp CLI binary for the whisper-cli provider
+	// OpenAI-compatible transcription endpoint for the openai provider; an
+	// empty APIKey falls back to llm.api_key
+	Model          string `yaml:"model"`
+	BaseURL        string `yaml:"base_url"`
+	APIKey         string `yaml:"api_key"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	// Streaming transcription re-decodes the last StreamWindowSeconds of
+	// audio every StreamStepMs
+	StreamStepMs        int `yaml:"stream_step_ms"`
+	StreamWindowSeconds int `yaml:"stream_window_seconds"`
 }
 
 type TTSConfig struct {
@@ -67,6 +142,7 @@ type ContextConfig struct {
 	SupportedExtensions  []string         `yaml:"supported_extensions"`
 	Chunking             ChunkingConfig   `yaml:"chunking"`
 	Embeddings           EmbeddingsConfig `yaml:"embeddings"`
+	OCR                  OCRConfig        `yaml:"ocr"`
 }
 
 type ChunkingConfig struct {
@@ -74,14 +150,34 @@ type ChunkingConfig struct {
 	ChunkSize    int    `yaml:"chunk_size"`
 	ChunkOverlap int    `yaml:"chunk_overlap"`
 	MinChunkSize int    `yaml:"min_chunk_size"`
+	// TokenizerVocab is the tiktoken vocab file used to measure chunks in tokens
+	TokenizerVocab string `yaml:"tokenizer_vocab"`
+	// SemanticBreakpoint is the percentile (0-100) of sentence-to-sentence
+	// embedding distance above which the semantic chunker starts a new chunk
+	SemanticBreakpoint float64 `yaml:"semantic_breakpoint_percentile"`
 }
 
 type EmbeddingsConfig struct {
-	Provider  string `yaml:"provider"`
-	Model     string `yaml:"model"`
-	Dimension int    `yaml:"dimension"`
-	CacheDir  string `yaml:"cache_dir"`
-	BatchSize int    `yaml:"batch_size"`
+	Provider          string `yaml:"provider"`
+	Model             string `yaml:"model"`
+	Dimension         int    `yaml:"dimension"`
+	CacheDir          string `yaml:"cache_dir"`
+	BatchSize         int    `yaml:"batch_size"`
+	RequestsPerMinute int    `yaml:"requests_per_minute"`
+	TokensPerMinute   int    `yaml:"tokens_per_minute"`
+	MaxRetries        int    `yaml:"max_retries"`
+	TimeoutSeconds    int    `yaml:"timeout_seconds"`
+}
+
+// OCRConfig controls text recognition for images and scanned PDF pages
+type OCRConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Command   string `yaml:"command"`   // tesseract binary
+	Languages string `yaml:"languages"` // tesseract language codes, e.g. "eng+deu"
+	// PDFRenderer is the pdftoppm binary used to rasterize scanned PDF pages
+	PDFRenderer    string `yaml:"pdf_renderer"`
+	DPI            int    `yaml:"dpi"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
 }
 
 type RetrievalConfig struct {
@@ -90,6 +186,12 @@ type RetrievalConfig struct {
 	Rerank              bool    `yaml:"rerank"`
 	StorageBackend      string  `yaml:"storage_backend"`
 	DBPath              string  `yaml:"db_path"`
+	// RewriteQueries has the LLM turn follow-ups like "what about its
+	// price?" into standalone search queries using the conversation
+	RewriteQueries bool `yaml:"rewrite_queries"`
+	// MultiQuery is how many paraphrases of the question to search for as
+	// well, merging the results; 0 searches for the question alone
+	MultiQuery int `yaml:"multi_query"`
 }
 
 type LLMConfig struct {
@@ -162,7 +264,7 @@ func Load(path string) (*Config, error) {
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.WakeWord.Word == "" {
+	if c.WakeWord.Word == "" && len(c.WakeWord.Phrases) == 0 {
 		return fmt.Errorf("wake_word.word cannot be empty")
 	}
 
@@ -178,6 +280,41 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("retrieval.top_k must be positive")
 	}
 
+	if err := c.Context.Chunking.Validate(); err != nil {
+		return err
+	}
+
+	if c.Context.Embeddings.BatchSize <= 0 {
+		return fmt.Errorf("context.embeddings.batch_size must be positive")
+	}
+
+	if c.Context.Embeddings.RequestsPerMinute < 0 || c.Context.Embeddings.TokensPerMinute < 0 {
+		return fmt.Errorf("context.embeddings rate limits cannot be negative")
+	}
+
+	return nil
+}
+
+// Validate checks the chunking parameters
+func (c *ChunkingConfig) Validate() error {
+	switch c.Method {
+	case "fixed", "recursive", "semantic":
+	default:
+		return fmt.Errorf("context.chunking.method must be one of fixed, recursive, semantic; got %q", c.Method)
+	}
+
+	if c.ChunkSize <= 0 {
+		return fmt.Errorf("context.chunking.chunk_size must be positive")
+	}
+
+	if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.ChunkSize {
+		return fmt.Errorf("context.chunking.chunk_overlap must be between 0 and chunk_size")
+	}
+
+	if c.SemanticBreakpoint < 0 || c.SemanticBreakpoint > 100 {
+		return fmt.Errorf("context.chunking.semantic_breakpoint_percentile must be between 0 and 100")
+	}
+
 	return nil
 }
 
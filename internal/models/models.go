@@ -10,12 +10,31 @@ type AudioChunk struct {
 	SampleRate int
 }
 
+// Utterance is a stretch of speech cut from the audio stream, including the
+// pre-roll before speech was detected and the hangover after it stopped
+type Utterance struct {
+	Data       []byte
+	Start      time.Time
+	Duration   time.Duration
+	SampleRate int
+}
+
 // TranscriptionResult represents STT output
 type TranscriptionResult struct {
 	Text       string
 	Language   string
 	Confidence float64
 	Duration   time.Duration
+	Segments   []TranscriptSegment // timed pieces of Text, when the provider reports them
+}
+
+// TranscriptSegment is a span of recognized speech, timed from the start of
+// the audio
+type TranscriptSegment struct {
+	Start      time.Duration
+	End        time.Duration
+	Text       string
+	Confidence float64 // mean token probability, 0 if the provider doesn't report one
 }
 
 // Document represents a parsed document
@@ -27,6 +46,11 @@ type Document struct {
 	Hash        string
 	ParsedAt    time.Time
 	FileModTime time.Time
+	PageOffsets []int    // byte offset in Content where each page starts, for paged formats
+	Warnings    []string // problems that did not stop parsing, such as unreadable pages
+	// OCRConfidence is the mean OCR word confidence (0-1) by 1-based page, for
+	// pages whose text was recognized from an image
+	OCRConfidence map[int]float64
 }
 
 // Chunk represents a text chunk
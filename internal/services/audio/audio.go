@@ -11,11 +11,13 @@ import (
 
 // Service manages audio input and output
 type Service struct {
-	cfg      *config.Config
-	recorder *Recorder
-	player   *Player
-	mu       sync.RWMutex
-	running  bool
+	cfg         *config.Config
+	recorder    *Recorder
+	player      *Player
+	mu          sync.RWMutex
+	running     bool
+	segmentOnce sync.Once
+	utterances  chan *models.Utterance
 }
 
 // NewService creates a new audio service
@@ -78,6 +80,35 @@ func (s *Service) GetAudioStream() <-chan *models.AudioChunk {
 	return s.recorder.GetStream()
 }
 
+// GetUtterances returns a channel of complete utterances, cut from the
+// audio stream by voice activity detection. It consumes the audio stream,
+// so it can't be combined with GetAudioStream.
+func (s *Service) GetUtterances() <-chan *models.Utterance {
+	s.segmentOnce.Do(func() {
+		s.utterances = make(chan *models.Utterance, 4)
+		go s.segmentLoop(s.recorder.GetStream())
+	})
+	return s.utterances
+}
+
+// segmentLoop groups recorded chunks into utterances until recording stops
+func (s *Service) segmentLoop(stream <-chan *models.AudioChunk) {
+	logger := utils.GetLogger()
+	segmenter := NewSegmenter(s.cfg)
+	defer close(s.utterances)
+
+	for chunk := range stream {
+		for _, u := range segmenter.Process(chunk) {
+			logger.Debugf("Utterance detected: %v of audio", u.Duration)
+			s.utterances <- u
+		}
+	}
+
+	if u := segmenter.Flush(); u != nil {
+		s.utterances <- u
+	}
+}
+
 // PlayAudio plays audio data through speakers
 func (s *Service) PlayAudio(audioData []byte) error {
 	return s.player.Play(audioData)
@@ -0,0 +1,211 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+)
+
+const (
+	mfccFrameMs     = 25
+	mfccHopMs       = 10
+	mfccFilters     = 26
+	mfccCoeffs      = 12 // cepstral coefficients kept, after dropping c0
+	mfccPreEmphasis = 0.97
+	// speechRangeDB is how far below the loudest frame a frame still counts
+	// as part of the speech rather than the background
+	speechRangeDB = 30
+)
+
+// mfccExtractor computes mel-frequency cepstral coefficients, the usual
+// compact description of what a short slice of speech sounds like
+type mfccExtractor struct {
+	frameLen int
+	hop      int
+	fftSize  int
+	window   []float64
+	filters  []melFilter
+	dct      [][]float64 // mfccCoeffs x mfccFilters
+}
+
+// melFilter is one triangular filter over FFT bins starting at start
+type melFilter struct {
+	start   int
+	weights []float64
+}
+
+func newMFCCExtractor(sampleRate int) *mfccExtractor {
+	frameLen := sampleRate * mfccFrameMs / 1000
+	fftSize := 1
+	for fftSize < frameLen {
+		fftSize <<= 1
+	}
+
+	window := make([]float64, frameLen)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameLen-1))
+	}
+
+	// Filter edges are evenly spaced on the mel scale up to the Nyquist frequency
+	mel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	hz := func(m float64) float64 { return 700 * (math.Pow(10, m/2595) - 1) }
+	top := mel(float64(sampleRate) / 2)
+	bins := make([]float64, mfccFilters+2)
+	for i := range bins {
+		bins[i] = hz(top*float64(i)/float64(mfccFilters+1)) * float64(fftSize) / float64(sampleRate)
+	}
+
+	filters := make([]melFilter, mfccFilters)
+	for f := range filters {
+		lo, mid, hi := bins[f], bins[f+1], bins[f+2]
+		start := int(math.Ceil(lo))
+		var weights []float64
+		for k := start; float64(k) < hi && k <= fftSize/2; k++ {
+			if float64(k) <= mid {
+				weights = append(weights, (float64(k)-lo)/(mid-lo))
+			} else {
+				weights = append(weights, (hi-float64(k))/(hi-mid))
+			}
+		}
+		filters[f] = melFilter{start: start, weights: weights}
+	}
+
+	dct := make([][]float64, mfccCoeffs)
+	for k := range dct {
+		dct[k] = make([]float64, mfccFilters)
+		for n := range dct[k] {
+			dct[k][n] = math.Cos(math.Pi * float64(k+1) * (float64(n) + 0.5) / mfccFilters)
+		}
+	}
+
+	return &mfccExtractor{
+		frameLen: frameLen,
+		hop:      sampleRate * mfccHopMs / 1000,
+		fftSize:  fftSize,
+		window:   window,
+		filters:  filters,
+		dct:      dct,
+	}
+}
+
+// features returns the coefficients of each frame of samples and the
+// frame's energy in dB
+func (m *mfccExtractor) features(samples []float64) (frames [][]float64, energy []float64) {
+	if len(samples) < m.frameLen {
+		return nil, nil
+	}
+
+	spectrum := make([]complex128, m.fftSize)
+	bands := make([]float64, mfccFilters)
+	for start := 0; start+m.frameLen <= len(samples); start += m.hop {
+		var power float64
+		for i := range spectrum {
+			spectrum[i] = 0
+			if i >= m.frameLen {
+				continue
+			}
+			s := samples[start+i]
+			power += s * s
+			if start+i > 0 {
+				s -= mfccPreEmphasis * samples[start+i-1]
+			}
+			spectrum[i] = complex(s*m.window[i], 0)
+		}
+		fft(spectrum)
+
+		for f, filter := range m.filters {
+			var sum float64
+			for i, w := range filter.weights {
+				c := spectrum[filter.start+i]
+				sum += w * (real(c)*real(c) + imag(c)*imag(c))
+			}
+			bands[f] = math.Log(math.Max(sum, 1e-10))
+		}
+
+		coeffs := make([]float64, mfccCoeffs)
+		for k, basis := range m.dct {
+			for n, b := range bands {
+				coeffs[k] += basis[n] * b
+			}
+		}
+		frames = append(frames, coeffs)
+		energy = append(energy, 10*math.Log10(math.Max(power/float64(m.frameLen), 1e-10)))
+	}
+	return frames, energy
+}
+
+// sampleCount returns the number of samples that make up frames frames
+func (m *mfccExtractor) sampleCount(frames int) int {
+	return (frames-1)*m.hop + m.frameLen
+}
+
+// normalizeFeatures scales each coefficient to zero mean and unit variance,
+// removing the microphone's and room's coloring. The statistics come from
+// frames within speechRangeDB of the loudest, so long pauses don't skew them.
+func normalizeFeatures(frames [][]float64, energy []float64) {
+	if len(frames) == 0 {
+		return
+	}
+
+	peak := math.Inf(-1)
+	for _, e := range energy {
+		peak = math.Max(peak, e)
+	}
+
+	for k := range frames[0] {
+		var sum, sq, n float64
+		for i, f := range frames {
+			if energy[i] < peak-speechRangeDB {
+				continue
+			}
+			sum += f[k]
+			sq += f[k] * f[k]
+			n++
+		}
+		mean := sum / n
+		std := math.Sqrt(math.Max(sq/n-mean*mean, 1e-10))
+		for _, f := range frames {
+			f[k] = (f[k] - mean) / std
+		}
+	}
+}
+
+// fft transforms x in place; len(x) must be a power of two
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// monoSamples converts interleaved 16-bit PCM to mono samples in [-1, 1]
+func monoSamples(pcm []byte, channels int) []float64 {
+	channels = max(channels, 1)
+	samples := make([]float64, len(pcm)/(2*channels))
+	for i := range samples {
+		var s float64
+		for c := 0; c < channels; c++ {
+			s += float64(int16(binary.LittleEndian.Uint16(pcm[2*(i*channels+c):])))
+		}
+		samples[i] = s / (float64(channels) * 32768)
+	}
+	return samples
+}
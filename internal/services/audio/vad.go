@@ -0,0 +1,279 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// VAD classifies short frames of 16-bit PCM as speech or silence by their
+// energy relative to a running noise floor and their zero-crossing rate
+type VAD struct {
+	marginDB   float64
+	minDB      float64
+	maxZCR     float64
+	channels   int
+	noiseFloor float64 // dBFS, tracked mostly over non-speech frames
+	primed     bool
+}
+
+// NewVAD creates a VAD from the audio configuration
+func NewVAD(cfg *config.Config) *VAD {
+	vadCfg := cfg.Audio.VAD
+
+	margin := vadCfg.EnergyMarginDB
+	if margin <= 0 {
+		margin = 10
+	}
+	// Each aggressiveness level asks for 3 dB more above the noise
+	margin += 3 * float64(clampInt(vadCfg.Aggressiveness, 0, 3))
+
+	minDB := vadCfg.MinEnergyDB
+	if minDB == 0 {
+		minDB = -50
+	}
+	maxZCR := vadCfg.MaxZeroCrossingRate
+	if maxZCR <= 0 {
+		maxZCR = 0.35
+	}
+
+	return &VAD{
+		marginDB: margin,
+		minDB:    minDB,
+		maxZCR:   maxZCR,
+		channels: max(cfg.Audio.Channels, 1),
+	}
+}
+
+// IsSpeech reports whether a frame contains speech, updating the noise
+// floor. The floor creeps up during speech too, slowly enough not to cut
+// off a sentence, so a lasting rise in background noise stops counting as
+// speech instead of making every frame speech.
+func (v *VAD) IsSpeech(frame []byte) bool {
+	energy, zcr := v.measure(frame)
+
+	if !v.primed {
+		v.noiseFloor, v.primed = energy, true
+	}
+
+	// Voiced speech is loud with few zero crossings. Fricatives like "s"
+	// cross zero often, so very loud frames count whatever their rate.
+	loud := energy >= v.minDB && energy >= v.noiseFloor+v.marginDB
+	speech := loud && (zcr <= v.maxZCR || energy >= v.noiseFloor+2*v.marginDB)
+
+	// Follow the floor down quickly and up slowly, so a burst of noise
+	// doesn't raise it as much as a quiet moment lowers it
+	rate := 0.02
+	switch {
+	case speech:
+		rate = 0.002
+	case energy < v.noiseFloor:
+		rate = 0.2
+	}
+	v.noiseFloor += rate * (energy - v.noiseFloor)
+
+	return speech
+}
+
+// measure returns a frame's energy in dBFS and zero-crossing rate, mixing
+// channels down to mono
+func (v *VAD) measure(frame []byte) (energy, zcr float64) {
+	samples := len(frame) / (2 * v.channels)
+	if samples == 0 {
+		return -100, 0
+	}
+
+	var sum float64
+	crossings := 0
+	prev := 0.0
+	for i := 0; i < samples; i++ {
+		var s float64
+		for c := 0; c < v.channels; c++ {
+			offset := 2 * (i*v.channels + c)
+			s += float64(int16(binary.LittleEndian.Uint16(frame[offset:])))
+		}
+		s /= float64(v.channels) * 32768
+
+		sum += s * s
+		if i > 0 && (s >= 0) != (prev >= 0) {
+			crossings++
+		}
+		prev = s
+	}
+
+	rms := math.Sqrt(sum / float64(samples))
+	return 20 * math.Log10(math.Max(rms, 1e-5)), float64(crossings) / float64(samples)
+}
+
+// Segmenter groups audio chunks into utterances: it opens one after a short
+// run of speech frames, keeping some pre-roll so the first syllable isn't
+// clipped, and closes it after a stretch of silence (the hangover)
+type Segmenter struct {
+	vad        *VAD
+	sampleRate int
+	frameBytes int
+	frameTime  time.Duration
+
+	startFrames    int
+	preRollFrames  int
+	hangoverFrames int
+	minFrames      int
+	maxFrames      int
+
+	pending  []byte    // bytes not yet making up a whole frame
+	clock    time.Time // capture time of the start of pending
+	preRoll  [][]byte
+	onset    int // consecutive speech frames while idle
+	speaking bool
+
+	current []byte
+	start   time.Time
+	frames  int
+	speech  int // speech frames in the current utterance
+	silence int // consecutive silent frames in the current utterance
+}
+
+// NewSegmenter creates a Segmenter from the audio configuration
+func NewSegmenter(cfg *config.Config) *Segmenter {
+	vadCfg := cfg.Audio.VAD
+
+	sampleRate := cfg.Audio.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	frameMs := vadCfg.FrameMs
+	if frameMs <= 0 {
+		frameMs = 30
+	}
+	frames := func(ms, fallback int) int {
+		if ms <= 0 {
+			ms = fallback
+		}
+		return max((ms+frameMs-1)/frameMs, 1)
+	}
+	maxSeconds := vadCfg.MaxUtteranceSeconds
+	if maxSeconds <= 0 {
+		maxSeconds = 30
+	}
+
+	return &Segmenter{
+		vad:            NewVAD(cfg),
+		sampleRate:     sampleRate,
+		frameBytes:     sampleRate * frameMs / 1000 * 2 * max(cfg.Audio.Channels, 1),
+		frameTime:      time.Duration(frameMs) * time.Millisecond,
+		startFrames:    frames(vadCfg.StartMs, 90) + clampInt(vadCfg.Aggressiveness, 0, 3),
+		preRollFrames:  frames(vadCfg.PreRollMs, 300),
+		hangoverFrames: frames(vadCfg.HangoverMs, 700),
+		minFrames:      frames(vadCfg.MinUtteranceMs, 250),
+		maxFrames:      maxSeconds * 1000 / frameMs,
+	}
+}
+
+// Process feeds a chunk of audio and returns the utterances it completed
+func (s *Segmenter) Process(chunk *models.AudioChunk) []*models.Utterance {
+	if len(s.pending) == 0 {
+		s.clock = chunk.Timestamp
+	}
+	s.pending = append(s.pending, chunk.Data...)
+
+	var done []*models.Utterance
+	for len(s.pending) >= s.frameBytes {
+		frame := s.pending[:s.frameBytes:s.frameBytes]
+		s.pending = s.pending[s.frameBytes:]
+		if u := s.frame(frame, s.clock); u != nil {
+			done = append(done, u)
+		}
+		s.clock = s.clock.Add(s.frameTime)
+	}
+
+	// Keep the tail in its own array so chunk buffers can be reused
+	s.pending = append([]byte(nil), s.pending...)
+	return done
+}
+
+// Flush ends the current utterance, if any, e.g. when recording stops
+func (s *Segmenter) Flush() *models.Utterance {
+	if !s.speaking {
+		return nil
+	}
+	return s.finish()
+}
+
+// frame advances the state machine by one frame
+func (s *Segmenter) frame(frame []byte, at time.Time) *models.Utterance {
+	speech := s.vad.IsSpeech(frame)
+
+	if !s.speaking {
+		s.preRoll = append(s.preRoll, frame)
+		if len(s.preRoll) > s.preRollFrames+s.startFrames {
+			s.preRoll = s.preRoll[1:]
+		}
+
+		if !speech {
+			s.onset = 0
+			return nil
+		}
+		s.onset++
+		if s.onset < s.startFrames {
+			return nil
+		}
+
+		// Open an utterance with the buffered frames, which include the onset
+		s.speaking = true
+		s.start = at.Add(-time.Duration(len(s.preRoll)-1) * s.frameTime)
+		s.current = nil
+		for _, f := range s.preRoll {
+			s.current = append(s.current, f...)
+		}
+		s.frames, s.speech, s.silence = len(s.preRoll), s.onset, 0
+		s.preRoll, s.onset = nil, 0
+		return nil
+	}
+
+	s.current = append(s.current, frame...)
+	s.frames++
+	if speech {
+		s.speech++
+		s.silence = 0
+	} else {
+		s.silence++
+	}
+
+	switch {
+	case s.silence >= s.hangoverFrames:
+		return s.finish()
+	case s.frames >= s.maxFrames:
+		// Cut overlong speech; the rest continues as a new utterance. The
+		// noise floor is kept: estimating it again from the middle of speech
+		// would take the speech for noise and lose the rest of it.
+		u := s.finish()
+		s.speaking, s.start = true, at.Add(s.frameTime)
+		s.current, s.frames, s.speech = nil, 0, 0
+		return u
+	}
+	return nil
+}
+
+// finish closes the current utterance, dropping it if it had too little speech
+func (s *Segmenter) finish() *models.Utterance {
+	s.speaking = false
+	data, frames, speech := s.current, s.frames, s.speech
+	s.current, s.frames, s.speech, s.silence = nil, 0, 0, 0
+
+	if speech < s.minFrames || len(data) == 0 {
+		return nil
+	}
+	return &models.Utterance{
+		Data:       data,
+		Start:      s.start,
+		Duration:   time.Duration(frames) * s.frameTime,
+		SampleRate: s.sampleRate,
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
@@ -0,0 +1,186 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// With the defaults a frame is 30 ms (960 bytes), an utterance opens on the
+// third speech frame, keeps 10 frames of pre-roll and closes after 24 silent
+// frames
+const (
+	testRate       = 16000
+	testFrameBytes = testRate * 30 / 1000 * 2
+)
+
+var testEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// signal builds 16-bit PCM test audio
+type signal struct {
+	data  []byte
+	noise *rand.Rand
+}
+
+func newSignal() *signal {
+	return &signal{noise: rand.New(rand.NewSource(1))}
+}
+
+// quiet appends faint background noise
+func (s *signal) quiet(ms int) *signal {
+	for i := 0; i < testRate*ms/1000; i++ {
+		s.sample(s.noise.NormFloat64() * 30)
+	}
+	return s
+}
+
+// voice appends a loud 200 Hz tone, which passes for voiced speech
+func (s *signal) voice(ms int) *signal {
+	for i := 0; i < testRate*ms/1000; i++ {
+		s.sample(8000 * math.Sin(2*math.Pi*200*float64(i)/testRate))
+	}
+	return s
+}
+
+func (s *signal) sample(v float64) {
+	s.data = binary.LittleEndian.AppendUint16(s.data, uint16(int16(v)))
+}
+
+// segment feeds audio to a Segmenter in 100 ms chunks, then flushes it
+func segment(t *testing.T, cfg *config.Config, audio []byte) []*models.Utterance {
+	t.Helper()
+
+	s := NewSegmenter(cfg)
+	const chunkBytes = testRate / 10 * 2
+
+	var utterances []*models.Utterance
+	for offset := 0; offset < len(audio); offset += chunkBytes {
+		chunk := &models.AudioChunk{
+			Data:      audio[offset:min(offset+chunkBytes, len(audio))],
+			Timestamp: testEpoch.Add(time.Duration(offset/2) * time.Second / testRate),
+		}
+		utterances = append(utterances, s.Process(chunk)...)
+	}
+	if u := s.Flush(); u != nil {
+		utterances = append(utterances, u)
+	}
+
+	for _, u := range utterances {
+		if want := time.Duration(len(u.Data)/testFrameBytes) * 30 * time.Millisecond; u.Duration != want {
+			t.Errorf("utterance at %v lasts %v but holds %v of audio", u.Start.Sub(testEpoch), u.Duration, want)
+		}
+		if u.SampleRate != testRate {
+			t.Errorf("sample rate = %d, want %d", u.SampleRate, testRate)
+		}
+	}
+	return utterances
+}
+
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Audio.SampleRate = testRate
+	return cfg
+}
+
+// offsetAt returns the byte offset of a time in the test audio
+func offsetAt(at time.Time) int {
+	return int(at.Sub(testEpoch)/time.Millisecond) * testRate / 1000 * 2
+}
+
+func TestSegmenterOnsetAndPreRoll(t *testing.T) {
+	// Speech starts on a frame boundary, at frame 33
+	audio := newSignal().quiet(990).voice(990).quiet(1500).data
+
+	utterances := segment(t, testConfig(), audio)
+	if len(utterances) != 1 {
+		t.Fatalf("got %d utterances, want 1", len(utterances))
+	}
+	u := utterances[0]
+
+	// The utterance opens 300 ms of pre-roll before the speech
+	if got, want := u.Start.Sub(testEpoch), 690*time.Millisecond; got != want {
+		t.Errorf("start = %v, want %v", got, want)
+	}
+	// ...and lasts through the speech and 24 frames of hangover
+	if got, want := u.Duration, (300+990+720)*time.Millisecond; got != want {
+		t.Errorf("duration = %v, want %v", got, want)
+	}
+	start := offsetAt(u.Start)
+	if !bytes.Equal(u.Data, audio[start:start+len(u.Data)]) {
+		t.Errorf("utterance data does not match the audio from its start")
+	}
+}
+
+func TestSegmenterHangover(t *testing.T) {
+	tests := []struct {
+		name string
+		gap  int // ms of silence between two bursts of speech
+		want int
+	}{
+		{"pause shorter than hangover", 450, 1},
+		{"pause longer than hangover", 900, 2},
+	}
+
+	for _, tt := range tests {
+		audio := newSignal().quiet(990).voice(600).quiet(tt.gap).voice(600).quiet(1500).data
+		if got := len(segment(t, testConfig(), audio)); got != tt.want {
+			t.Errorf("%s: got %d utterances, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSegmenterDropsShortUtterances(t *testing.T) {
+	// 150 ms of speech opens an utterance but falls short of the 250 ms minimum
+	audio := newSignal().quiet(990).voice(150).quiet(1500).data
+	if utterances := segment(t, testConfig(), audio); len(utterances) != 0 {
+		t.Errorf("got %d utterances, want the short one dropped", len(utterances))
+	}
+
+	// The same applies when recording stops mid-speech
+	audio = newSignal().quiet(990).voice(150).data
+	if utterances := segment(t, testConfig(), audio); len(utterances) != 0 {
+		t.Errorf("flush: got %d utterances, want the short one dropped", len(utterances))
+	}
+}
+
+// Speech longer than the maximum is cut into back-to-back utterances that
+// together hold all of it
+func TestSegmenterMaxLengthCut(t *testing.T) {
+	cfg := testConfig()
+	cfg.Audio.VAD.MaxUtteranceSeconds = 2
+	audio := newSignal().quiet(990).voice(5000).quiet(1500).data
+
+	utterances := segment(t, cfg, audio)
+	if len(utterances) < 3 {
+		t.Fatalf("got %d utterances, want 5 s of speech in at least 3", len(utterances))
+	}
+
+	start := offsetAt(utterances[0].Start)
+	var joined []byte
+	for i, u := range utterances {
+		if u.Duration > 2*time.Second {
+			t.Errorf("utterance %d lasts %v, longer than the maximum", i, u.Duration)
+		}
+		if i > 0 {
+			prev := utterances[i-1]
+			if want := prev.Start.Add(prev.Duration); !u.Start.Equal(want) {
+				t.Errorf("utterance %d starts at %v, want %v right after the previous one", i, u.Start.Sub(testEpoch), want.Sub(testEpoch))
+			}
+		}
+		joined = append(joined, u.Data...)
+	}
+
+	speechEnd := offsetAt(testEpoch.Add((990 + 5000) * time.Millisecond))
+	if end := start + len(joined); end < speechEnd {
+		t.Errorf("utterances end at %v, before the speech ends at 5.99s", time.Duration(end/2)*time.Second/testRate)
+	}
+	if !bytes.Equal(joined, audio[start:start+len(joined)]) {
+		t.Errorf("utterance data does not match the audio")
+	}
+}
@@ -0,0 +1,225 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+const (
+	defaultWakeWordThreshold = 3.2
+	// wakeWordLead is how far into an utterance the wake word may start when
+	// it must come first, covering the segmenter's pre-roll and onset
+	wakeWordLeadMs = 1000
+)
+
+// WakeWordSpotter detects the wake word in raw audio by comparing MFCCs
+// against recordings of it with dynamic time warping. It is far cheaper than
+// transcription, so it can screen every utterance before STT runs.
+type WakeWordSpotter struct {
+	mfcc      *mfccExtractor
+	channels  int
+	templates []wakeWordTemplate
+	threshold float64
+	// leadFrames limits the search to the start of the utterance, or is 0
+	// when the wake word may come anywhere
+	leadFrames int
+	longest    int // frames in the longest template
+}
+
+type wakeWordTemplate struct {
+	name   string
+	frames [][]float64
+}
+
+// NewWakeWordSpotter loads the wake word recordings from the configured
+// templates directory
+func NewWakeWordSpotter(cfg *config.Config) (*WakeWordSpotter, error) {
+	acoustic := cfg.WakeWord.Acoustic
+
+	sampleRate := cfg.Audio.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	threshold := acoustic.Threshold
+	if threshold <= 0 {
+		threshold = defaultWakeWordThreshold
+	}
+
+	s := &WakeWordSpotter{
+		mfcc:      newMFCCExtractor(sampleRate),
+		channels:  max(cfg.Audio.Channels, 1),
+		threshold: threshold,
+	}
+
+	if acoustic.Templates == "" {
+		return nil, fmt.Errorf("wake_word.acoustic.templates must be set")
+	}
+	paths, err := filepath.Glob(filepath.Join(acoustic.Templates, "*.wav"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wake word templates: %w", err)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		frames, err := s.loadTemplate(path, sampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load wake word template %s: %w", path, err)
+		}
+		s.templates = append(s.templates, wakeWordTemplate{name: filepath.Base(path), frames: frames})
+		s.longest = max(s.longest, len(frames))
+	}
+	if len(s.templates) == 0 {
+		return nil, fmt.Errorf("no wake word templates in %s; record a few takes of %q as WAV files there", acoustic.Templates, cfg.WakeWord.Word)
+	}
+
+	if cfg.WakeWord.MatchType != "contains" {
+		s.leadFrames = wakeWordLeadMs / mfccHopMs
+	}
+
+	utils.GetLogger().Infof("Acoustic wake word spotting enabled with %d templates (threshold %.2f)", len(s.templates), threshold)
+	return s, nil
+}
+
+// Detect reports whether 16-bit PCM audio contains the wake word, along with
+// the distance to the closest template
+func (s *WakeWordSpotter) Detect(pcm []byte) (bool, float64) {
+	samples := monoSamples(pcm, s.channels)
+	if s.leadFrames > 0 {
+		if limit := s.mfcc.sampleCount(s.searchFrames()); len(samples) > limit {
+			samples = samples[:limit]
+		}
+	}
+
+	query, energy := s.mfcc.features(samples)
+	if len(query) == 0 {
+		return false, math.Inf(1)
+	}
+	normalizeFeatures(query, energy)
+
+	best, match := math.Inf(1), ""
+	for _, t := range s.templates {
+		if d := alignTemplate(t.frames, query); d < best {
+			best, match = d, t.name
+		}
+	}
+
+	utils.GetLogger().Debugf("Wake word distance %.2f (template %s, threshold %.2f)", best, match, s.threshold)
+	return best <= s.threshold, best
+}
+
+// Window returns how many bytes from the start of an utterance Detect looks
+// at, or 0 when it searches the whole utterance
+func (s *WakeWordSpotter) Window() int {
+	if s.leadFrames == 0 {
+		return 0
+	}
+	return s.mfcc.sampleCount(s.searchFrames()) * 2 * s.channels
+}
+
+// searchFrames is the span in which a wake word that comes first must end:
+// the lead plus a slowly spoken take of the longest template
+func (s *WakeWordSpotter) searchFrames() int {
+	return s.leadFrames + 2*s.longest
+}
+
+// loadTemplate reads a WAV recording, trims the silence around the word and returns
+// its normalized features
+func (s *WakeWordSpotter) loadTemplate(path string, sampleRate int) ([][]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := DecodeWAV(data, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	frames, energy := s.mfcc.features(samples)
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("recording is too short")
+	}
+
+	peak := math.Inf(-1)
+	for _, e := range energy {
+		peak = math.Max(peak, e)
+	}
+	first, last := 0, len(frames)-1
+	for first < last && energy[first] < peak-speechRangeDB {
+		first++
+	}
+	for last > first && energy[last] < peak-speechRangeDB {
+		last--
+	}
+	frames, energy = frames[first:last+1], energy[first:last+1]
+	if len(frames) < 10 {
+		return nil, fmt.Errorf("recording has under 100ms of sound")
+	}
+
+	normalizeFeatures(frames, energy)
+	return frames, nil
+}
+
+// alignTemplate finds the stretch of query that best matches template using
+// subsequence DTW, which lets the template start and end at any frame. It
+// returns the mean frame distance along the best path, ignoring alignments
+// that squeeze or stretch the template more than twofold.
+func alignTemplate(template, query [][]float64) float64 {
+	m, n := len(template), len(query)
+	if m == 0 || n < (m+1)/2 {
+		return math.Inf(1)
+	}
+
+	// Cost, path length and starting query frame of the best path to each
+	// cell, one template row at a time
+	type cell struct {
+		cost   float64
+		length int
+		start  int
+	}
+	prev, cur := make([]cell, n), make([]cell, n)
+	for j := range prev {
+		prev[j] = cell{frameDistance(template[0], query[j]), 1, j}
+	}
+
+	for i := 1; i < m; i++ {
+		for j := range cur {
+			d := frameDistance(template[i], query[j])
+			best := prev[j] // template advances, query waits
+			if j > 0 {
+				if c := prev[j-1]; c.cost < best.cost {
+					best = c
+				}
+				if c := cur[j-1]; c.cost < best.cost {
+					best = c
+				}
+			}
+			cur[j] = cell{best.cost + d, best.length + 1, best.start}
+		}
+		prev, cur = cur, prev
+	}
+
+	result := math.Inf(1)
+	for j, c := range prev {
+		span := j - c.start + 1
+		if 2*span < m || span > 2*m {
+			continue
+		}
+		result = math.Min(result, c.cost/float64(c.length))
+	}
+	return result
+}
+
+func frameDistance(a, b []float64) float64 {
+	var sum float64
+	for k := range a {
+		d := a[k] - b[k]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
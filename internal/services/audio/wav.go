@@ -0,0 +1,162 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// WAVE format codes
+const (
+	wavePCM        = 1
+	waveFloat      = 3
+	waveExtensible = 0xFFFE
+)
+
+// DecodeWAV reads a RIFF WAVE file in any common PCM or float encoding as
+// mono samples in [-1, 1] at sampleRate
+func DecodeWAV(data []byte, sampleRate int) ([]float64, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a RIFF WAVE file")
+	}
+
+	var format, channels, bits int
+	var rate int
+	var samples []byte
+	haveFormat := false
+
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		// Streamed recordings may leave the data size unset
+		if size > len(body) || size < 0 {
+			size = len(body)
+		}
+		body = body[:size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("WAVE format chunk too short")
+			}
+			format = int(binary.LittleEndian.Uint16(body[0:2]))
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			rate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bits = int(binary.LittleEndian.Uint16(body[14:16]))
+			if format == waveExtensible && size >= 26 {
+				format = int(binary.LittleEndian.Uint16(body[24:26]))
+			}
+			haveFormat = true
+		case "data":
+			samples = body
+		}
+
+		pos += 8 + size + size%2
+	}
+
+	if !haveFormat {
+		return nil, fmt.Errorf("WAVE file has no format chunk")
+	}
+	if channels <= 0 || rate <= 0 {
+		return nil, fmt.Errorf("invalid WAVE format: %d channels at %d Hz", channels, rate)
+	}
+
+	sample, err := waveSampleReader(format, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	width := bits / 8
+	frames := len(samples) / (width * channels)
+	mono := make([]float64, frames)
+	for i := range mono {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			offset := (i*channels + c) * width
+			sum += sample(samples[offset : offset+width])
+		}
+		mono[i] = sum / float64(channels)
+	}
+
+	return resample(mono, rate, sampleRate), nil
+}
+
+// waveSampleReader returns a function converting one sample to [-1, 1]
+func waveSampleReader(format, bits int) (func([]byte) float64, error) {
+	switch {
+	case format == wavePCM && bits == 8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }, nil
+	case format == wavePCM && bits == 16:
+		return func(b []byte) float64 {
+			return float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+		}, nil
+	case format == wavePCM && bits == 24:
+		return func(b []byte) float64 {
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			return float64(v) / (1 << 23)
+		}, nil
+	case format == wavePCM && bits == 32:
+		return func(b []byte) float64 {
+			return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+		}, nil
+	case format == waveFloat && bits == 32:
+		return func(b []byte) float64 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}, nil
+	case format == waveFloat && bits == 64:
+		return func(b []byte) float64 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported WAVE encoding: format %d, %d bits", format, bits)
+}
+
+// resample converts samples between rates, averaging the input under each
+// output sample when downsampling so high frequencies don't alias into speech
+// and interpolating linearly when upsampling
+func resample(samples []float64, from, to int) []float64 {
+	if from == to || len(samples) == 0 {
+		return samples
+	}
+
+	n := int(int64(len(samples)) * int64(to) / int64(from))
+	result := make([]float64, n)
+	step := float64(from) / float64(to)
+
+	if step > 1 {
+		for i := range result {
+			start, end := int(float64(i)*step), int(float64(i+1)*step)
+			end = min(max(end, start+1), len(samples))
+			var sum float64
+			for _, s := range samples[start:end] {
+				sum += s
+			}
+			result[i] = sum / float64(end-start)
+		}
+		return result
+	}
+
+	for i := range result {
+		pos := float64(i) * step
+		j := int(pos)
+		if j+1 >= len(samples) {
+			result[i] = samples[len(samples)-1]
+			continue
+		}
+		frac := pos - float64(j)
+		result[i] = samples[j]*(1-frac) + samples[j+1]*frac
+	}
+	return result
+}
+
+// PCM16 encodes samples in [-1, 1] as 16-bit little-endian PCM
+func PCM16(samples []float64) []byte {
+	out := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		v := math.Round(s * 32767)
+		v = math.Max(-32768, math.Min(32767, v))
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(v)))
+	}
+	return out
+}
@@ -0,0 +1,324 @@
+package context
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// codeLanguages maps source file extensions to languages
+var codeLanguages = map[string]string{
+	".go":  "go",
+	".py":  "python",
+	".ts":  "typescript",
+	".tsx": "typescript",
+	".js":  "javascript",
+	".jsx": "javascript",
+}
+
+var (
+	pythonDeclRe = regexp.MustCompile(`^(?:async\s+def|def|class)\s+([A-Za-z_]\w*)`)
+	braceDeclRe  = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?(?:async\s+)?` +
+		`(?:function\*?|class|interface|type|enum|const|let|var|namespace|module)\s+([A-Za-z_$][\w$]*)`)
+)
+
+// codeUnit is a top-level declaration and the lines leading up to it
+type codeUnit struct {
+	symbol    string
+	startLine int // 1-based, inclusive
+	endLine   int // 1-based, inclusive
+}
+
+// isSourceCode reports whether a document should use the code chunker
+func isSourceCode(doc *models.Document) bool {
+	_, ok := codeLanguages[doc.Metadata["extension"]]
+	return ok
+}
+
+// chunkCode splits source files along function and type boundaries.
+// Small neighbouring declarations share a chunk; declarations larger than
+// size are split by lines. Symbols and line ranges go into the metadata.
+func (e *Embedder) chunkCode(doc *models.Document, text string, size int) []*models.Chunk {
+	language := codeLanguages[doc.Metadata["extension"]]
+	lines := strings.Split(text, "\n")
+
+	var units []codeUnit
+	switch language {
+	case "go":
+		units = goUnits(text, len(lines))
+	case "python":
+		units = indentUnits(lines)
+	default:
+		units = braceUnits(lines)
+	}
+
+	var chunks []*models.Chunk
+	emit := func(symbols []string, start, end int) {
+		content := strings.Join(lines[start-1:end], "\n")
+		if strings.TrimSpace(content) == "" {
+			return
+		}
+
+		chunk := newChunk(doc, content, len(chunks))
+		chunk.Metadata["language"] = language
+		chunk.Metadata["symbols"] = strings.Join(symbols, ", ")
+		chunk.Metadata["start_line"] = start
+		chunk.Metadata["end_line"] = end
+		chunks = append(chunks, chunk)
+	}
+
+	var symbols []string
+	start, tokens := 0, 0
+	flush := func(end int) {
+		if start > 0 {
+			emit(symbols, start, end)
+		}
+		symbols, start, tokens = nil, 0, 0
+	}
+
+	for _, unit := range units {
+		unitTokens := e.tokenizer.Count(strings.Join(lines[unit.startLine-1:unit.endLine], "\n"))
+
+		if tokens+unitTokens > size && start > 0 {
+			flush(unit.startLine - 1)
+		}
+
+		// Oversized declarations are split into line windows
+		if unitTokens > size {
+			for _, window := range e.lineWindows(lines, unit.startLine, unit.endLine, size) {
+				emit([]string{unit.symbol}, window[0], window[1])
+			}
+			continue
+		}
+
+		if start == 0 {
+			start = unit.startLine
+		}
+		if unit.symbol != "" {
+			symbols = append(symbols, unit.symbol)
+		}
+		tokens += unitTokens
+	}
+
+	if len(units) > 0 {
+		flush(units[len(units)-1].endLine)
+	}
+
+	return chunks
+}
+
+// lineWindows splits lines [start, end] into ranges of at most size tokens
+func (e *Embedder) lineWindows(lines []string, start, end, size int) [][2]int {
+	var windows [][2]int
+	from, tokens := start, 0
+
+	for i := start; i <= end; i++ {
+		lineTokens := e.tokenizer.Count(lines[i-1]) + 1
+		if tokens+lineTokens > size && i > from {
+			windows = append(windows, [2]int{from, i - 1})
+			from, tokens = i, 0
+		}
+		tokens += lineTokens
+	}
+
+	return append(windows, [2]int{from, end})
+}
+
+// goUnits uses go/parser to find top-level declarations. Files that don't
+// parse fall back to the brace heuristic.
+func goUnits(src string, lineCount int) []codeUnit {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return braceUnits(strings.Split(src, "\n"))
+	}
+
+	units := []codeUnit{{symbol: "package " + file.Name.Name, endLine: fset.Position(file.Name.End()).Line}}
+	for _, decl := range file.Decls {
+		end := fset.Position(decl.End()).Line
+
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			units = append(units, codeUnit{symbol: goFuncName(d), endLine: end})
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				// Imports belong with the package clause
+				units[0].endLine = end
+				continue
+			}
+			units = append(units, codeUnit{symbol: goGenDeclName(d), endLine: end})
+		}
+	}
+
+	return contiguousUnits(units, lineCount)
+}
+
+func goFuncName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	prefix := ""
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv, prefix = star.X, "*"
+	}
+	if index, ok := recv.(*ast.IndexExpr); ok {
+		recv = index.X
+	}
+	if index, ok := recv.(*ast.IndexListExpr); ok {
+		recv = index.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return fmt.Sprintf("(%s%s).%s", prefix, ident.Name, fn.Name.Name)
+	}
+	return fn.Name.Name
+}
+
+func goGenDeclName(decl *ast.GenDecl) string {
+	var names []string
+	for _, spec := range decl.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, s.Name.Name)
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				names = append(names, name.Name)
+			}
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// indentUnits splits Python source at top-level def and class statements,
+// keeping decorators and comments directly above with the declaration
+func indentUnits(lines []string) []codeUnit {
+	units := []codeUnit{{startLine: 1}}
+
+	for i, line := range lines {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if m := pythonDeclRe.FindStringSubmatch(line); m != nil {
+			units = append(units, codeUnit{symbol: m[1], startLine: leadingLines(lines, i, "#", "@") + 1})
+		}
+	}
+
+	return boundedUnits(units, len(lines))
+}
+
+// braceUnits splits C-like source (TypeScript, JavaScript) at declarations
+// that start at brace depth zero, keeping comments and decorators directly above
+func braceUnits(lines []string) []codeUnit {
+	units := []codeUnit{{startLine: 1}}
+	depth := 0
+	inBlockComment := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if depth == 0 && !inBlockComment {
+			if m := braceDeclRe.FindStringSubmatch(trimmed); m != nil {
+				units = append(units, codeUnit{symbol: m[1], startLine: leadingLines(lines, i, "//", "/*", "*", "@") + 1})
+			}
+		}
+		depth, inBlockComment = braceDepth(line, depth, inBlockComment)
+	}
+
+	return boundedUnits(units, len(lines))
+}
+
+// braceDepth updates the brace nesting depth for one line, skipping braces
+// inside strings and comments
+func braceDepth(line string, depth int, inBlockComment bool) (int, bool) {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inBlockComment:
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				inBlockComment = false
+				i++
+			}
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return depth, false
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			inBlockComment = true
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		}
+	}
+	return depth, inBlockComment
+}
+
+// leadingLines returns the index of the first line of the comment or
+// decorator block directly above lines[i]
+func leadingLines(lines []string, i int, prefixes ...string) int {
+	start := i
+	for start > 0 {
+		prev := strings.TrimSpace(lines[start-1])
+		matched := false
+		for _, prefix := range prefixes {
+			if prev != "" && strings.HasPrefix(prev, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			break
+		}
+		start--
+	}
+	return start
+}
+
+// boundedUnits sets each unit's end to the line before the next unit starts
+func boundedUnits(units []codeUnit, lineCount int) []codeUnit {
+	var result []codeUnit
+	for i, unit := range units {
+		end := lineCount
+		if i+1 < len(units) {
+			end = units[i+1].startLine - 1
+		}
+		if end >= unit.startLine {
+			unit.endLine = end
+			result = append(result, unit)
+		}
+	}
+	return result
+}
+
+// contiguousUnits sets each unit's start to the line after the previous unit
+// ends, so comments between declarations stay with the one that follows
+func contiguousUnits(units []codeUnit, lineCount int) []codeUnit {
+	var result []codeUnit
+	next := 1
+	for _, unit := range units {
+		if unit.endLine < next {
+			continue
+		}
+		unit.startLine = next
+		result = append(result, unit)
+		next = unit.endLine + 1
+	}
+
+	if next <= lineCount && len(result) > 0 {
+		result[len(result)-1].endLine = lineCount
+	}
+	return result
+}
@@ -0,0 +1,454 @@
+package context
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// Email documents hold one or more messages separated by a form feed line.
+// Each message starts with its headers, then a blank line and the body.
+const emailSeparator = "\f"
+
+// emailHeaders are the headers kept in the rendered message, in order, with
+// their metadata keys
+var emailHeaders = []struct{ name, key string }{
+	{"From", "from"},
+	{"To", "to"},
+	{"Cc", "cc"},
+	{"Subject", "subject"},
+	{"Date", "date"},
+	{"Message-ID", "message_id"},
+	{"In-Reply-To", "in_reply_to"},
+}
+
+var (
+	replyPrefixRe     = regexp.MustCompile(`(?i)^\s*((re|fw|fwd|aw|sv|wg)\s*(\[\d+\])?\s*:\s*)+`)
+	attributionRe     = regexp.MustCompile(`(?i)^(on\s.+|.+\s(wrote|writes|a écrit|schrieb|escribió))\s*:$`)
+	originalMessageRe = regexp.MustCompile(`(?i)^\s*-{2,}\s*(original message|ursprüngliche nachricht|message d'origine)\s*-{2,}\s*$`)
+	outlookHeaderRe   = regexp.MustCompile(`(?i)^\s*\*?(from|von|de)\s*:\*?\s`)
+	outlookSentRe     = regexp.MustCompile(`(?i)^\s*\*?(sent|date|gesendet|envoyé)\s*:\*?\s`)
+)
+
+// emailMessage is one parsed message
+type emailMessage struct {
+	headers     map[string]string // keyed by metadata key
+	body        string
+	attachments []string
+}
+
+// isEmail reports whether a document should use the email chunker
+func isEmail(doc *models.Document) bool {
+	return doc.Metadata["content_format"] == "email"
+}
+
+// parseEML reads a single RFC 5322 message
+func parseEML(filePath string, doc *models.Document) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	msg, err := readEmail(file)
+	if err != nil {
+		return fmt.Errorf("failed to parse email: %w", err)
+	}
+
+	doc.Content = renderEmail(msg)
+	doc.Metadata["content_format"] = "email"
+	doc.Metadata["messages"] = "1"
+	setMetadata(doc.Metadata, msg.headers)
+	setMetadata(doc.Metadata, map[string]string{
+		"thread":      emailThread(msg.headers["subject"]),
+		"attachments": strings.Join(msg.attachments, ", "),
+	})
+
+	return nil
+}
+
+// parseMbox reads a Unix mailbox, one message per "From " line
+func parseMbox(filePath string, doc *models.Document) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var content strings.Builder
+	var first, last time.Time
+	count := 0
+
+	addMessage := func(raw []byte) {
+		if len(bytes.TrimSpace(raw)) == 0 {
+			return
+		}
+		msg, err := readEmail(bytes.NewReader(raw))
+		if err != nil {
+			utils.GetLogger().Warnf("Skipping unreadable message %d in %s: %v", count+1, filePath, err)
+			return
+		}
+
+		if content.Len() > 0 {
+			content.WriteString(emailSeparator + "\n")
+		}
+		content.WriteString(renderEmail(msg))
+		count++
+
+		if date, err := time.Parse(time.RFC3339, msg.headers["date"]); err == nil {
+			if first.IsZero() || date.Before(first) {
+				first = date
+			}
+			if date.After(last) {
+				last = date
+			}
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	var current bytes.Buffer
+	prevBlank := true
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case prevBlank && bytes.HasPrefix(line, []byte("From ")):
+				addMessage(current.Bytes())
+				current.Reset()
+			case isEscapedFrom(line):
+				// mboxrd quoting: ">From " in a body is an escaped "From "
+				current.Write(line[1:])
+			default:
+				current.Write(line)
+			}
+			prevBlank = len(bytes.TrimSpace(line)) == 0
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read mbox: %w", err)
+		}
+	}
+	addMessage(current.Bytes())
+
+	if count == 0 {
+		return fmt.Errorf("failed to parse mbox: no messages found")
+	}
+
+	doc.Content = content.String()
+	doc.Metadata["content_format"] = "email"
+	doc.Metadata["messages"] = strconv.Itoa(count)
+	if !first.IsZero() {
+		doc.Metadata["first_date"] = first.Format(time.RFC3339)
+		doc.Metadata["last_date"] = last.Format(time.RFC3339)
+	}
+
+	return nil
+}
+
+// isEscapedFrom reports whether line is a ">From " (or ">>From ") line
+func isEscapedFrom(line []byte) bool {
+	trimmed := bytes.TrimLeft(line, ">")
+	return len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From "))
+}
+
+// readEmail parses headers and the readable body of a message
+func readEmail(r io.Reader) (*emailMessage, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &emailMessage{headers: make(map[string]string)}
+	dec := new(mime.WordDecoder)
+
+	for _, h := range emailHeaders {
+		value := msg.Header.Get(h.name)
+		if value == "" {
+			continue
+		}
+
+		switch h.key {
+		case "from", "to", "cc":
+			value = formatAddresses(msg.Header, h.name, dec)
+		case "date":
+			if date, err := mail.ParseDate(value); err == nil {
+				value = date.Format(time.RFC3339)
+			}
+		default:
+			if decoded, err := dec.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+		}
+		result.headers[h.key] = collapseSpace(value)
+	}
+
+	body, err := emailBody(msg.Header.Get("Content-Type"), msg.Header, msg.Body, result)
+	if err != nil {
+		return nil, err
+	}
+	result.body = stripQuotedReply(body)
+
+	return result, nil
+}
+
+// formatAddresses renders an address header as "Name <addr>, ...",
+// falling back to the raw (decoded) value if it doesn't parse
+func formatAddresses(header mail.Header, name string, dec *mime.WordDecoder) string {
+	list, err := header.AddressList(name)
+	if err != nil {
+		decoded, err := dec.DecodeHeader(header.Get(name))
+		if err != nil {
+			return header.Get(name)
+		}
+		return decoded
+	}
+
+	formatted := make([]string, len(list))
+	for i, addr := range list {
+		formatted[i] = addr.Address
+		if addr.Name != "" {
+			formatted[i] = addr.Name + " <" + addr.Address + ">"
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// emailBody returns the readable text of a message part, preferring
+// text/plain alternatives and converting HTML-only bodies. Attachment file
+// names are recorded on msg.
+func emailBody(contentType string, header interface{ Get(string) string }, body io.Reader, msg *emailMessage) (string, error) {
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if disposition, dparams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && disposition == "attachment" {
+		name := dparams["filename"]
+		if name == "" {
+			name = params["name"]
+		}
+		if name != "" {
+			msg.attachments = append(msg.attachments, name)
+		}
+		return "", nil
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+
+		var parts []string
+		var plain, html string
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+
+			text, err := emailBody(part.Header.Get("Content-Type"), part.Header, part, msg)
+			if err != nil || strings.TrimSpace(text) == "" {
+				continue
+			}
+
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			switch {
+			case mediaType != "multipart/alternative":
+				parts = append(parts, text)
+			case partType == "text/html":
+				html = text
+			case plain == "":
+				plain = text
+			}
+		}
+
+		if mediaType == "multipart/alternative" {
+			if plain != "" {
+				return plain, nil
+			}
+			return html, nil
+		}
+		return strings.Join(parts, "\n\n"), nil
+
+	case mediaType == "text/plain" || mediaType == "text/html":
+		data, err := decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+		if err != nil {
+			return "", err
+		}
+		text := decodeText(data)
+		if mediaType == "text/html" {
+			text, _ = htmlToMarkdown(text)
+		}
+		return strings.ReplaceAll(text, "\r\n", "\n"), nil
+
+	case mediaType == "message/rfc822":
+		// A forwarded message as an attachment
+		inner, err := readEmail(body)
+		if err != nil {
+			return "", nil
+		}
+		return renderEmail(inner), nil
+	}
+
+	return "", nil
+}
+
+// stripQuotedReply removes quoted text from a reply: ">" lines, the
+// "On ... wrote:" attribution and Outlook-style original message blocks
+func stripQuotedReply(body string) string {
+	lines := strings.Split(body, "\n")
+
+	var kept []string
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t\r")
+		trimmed := strings.TrimSpace(line)
+
+		// Everything below an original message marker is the quoted thread
+		if originalMessageRe.MatchString(line) {
+			break
+		}
+		if outlookHeaderRe.MatchString(line) && i+1 < len(lines) && outlookSentRe.MatchString(lines[i+1]) {
+			break
+		}
+
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+
+		// Attribution lines, which may wrap onto a second line, introduce a quote
+		if n := attributionLines(lines, i); n > 0 {
+			i += n - 1
+			continue
+		}
+
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// attributionLines returns how many lines starting at lines[i] form an
+// attribution ("On ... wrote:") followed by quoted text, or 0
+func attributionLines(lines []string, i int) int {
+	quoteFollows := func(j int) bool {
+		for ; j < len(lines); j++ {
+			if t := strings.TrimSpace(lines[j]); t != "" {
+				return strings.HasPrefix(t, ">")
+			}
+		}
+		return false
+	}
+
+	line := strings.TrimSpace(lines[i])
+	if line == "" {
+		return 0
+	}
+	if attributionRe.MatchString(line) && quoteFollows(i+1) {
+		return 1
+	}
+	if i+1 < len(lines) && strings.HasPrefix(strings.ToLower(line), "on ") {
+		joined := line + " " + strings.TrimSpace(lines[i+1])
+		if attributionRe.MatchString(joined) && quoteFollows(i+2) {
+			return 2
+		}
+	}
+	return 0
+}
+
+// renderEmail writes a message as header lines, a blank line and the body
+func renderEmail(msg *emailMessage) string {
+	var b strings.Builder
+	for _, h := range emailHeaders {
+		if value := msg.headers[h.key]; value != "" {
+			b.WriteString(h.name + ": " + value + "\n")
+		}
+	}
+	if len(msg.attachments) > 0 {
+		b.WriteString("Attachments: " + strings.Join(msg.attachments, ", ") + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(msg.body)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// emailThread normalizes a subject to identify its thread
+func emailThread(subject string) string {
+	return strings.TrimSpace(replyPrefixRe.ReplaceAllString(subject, ""))
+}
+
+// chunkEmail chunks each message separately. Every chunk starts with the
+// message's subject, sender and date, and carries its headers as metadata
+// so results can be scoped by sender, thread or date.
+func (e *Embedder) chunkEmail(doc *models.Document, text string, size, overlap int) []*models.Chunk {
+	var chunks []*models.Chunk
+
+	for _, message := range strings.Split(text, emailSeparator+"\n") {
+		head, body, _ := strings.Cut(message, "\n\n")
+
+		metadata := make(map[string]string)
+		for _, line := range strings.Split(head, "\n") {
+			name, value, ok := strings.Cut(line, ": ")
+			if !ok {
+				continue
+			}
+			for _, h := range emailHeaders {
+				if h.name == name {
+					metadata[h.key] = value
+				}
+			}
+		}
+		metadata["thread"] = emailThread(metadata["subject"])
+
+		var prefix strings.Builder
+		for _, name := range []string{"Subject", "From", "Date"} {
+			for _, h := range emailHeaders {
+				if h.name == name && metadata[h.key] != "" {
+					prefix.WriteString(name + ": " + metadata[h.key] + "\n")
+				}
+			}
+		}
+
+		budget := size - e.tokenizer.Count(prefix.String())
+		if budget < size/2 {
+			budget = size / 2
+		}
+
+		bodyChunks := e.chunkRecursive(doc, body, budget, overlap)
+		if len(bodyChunks) == 0 {
+			// A message without a body is still worth finding by subject
+			bodyChunks = []*models.Chunk{newChunk(doc, "", 0)}
+		}
+
+		for _, bodyChunk := range bodyChunks {
+			chunk := newChunk(doc, prefix.String()+"\n"+bodyChunk.Content, len(chunks))
+			for key, value := range metadata {
+				if value != "" {
+					chunk.Metadata[key] = value
+				}
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	return chunks
+}
@@ -0,0 +1,130 @@
+package context
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var embeddingsBucket = []byte("embeddings")
+
+var (
+	embeddingCachesMu sync.Mutex
+	embeddingCaches   = make(map[string]*embeddingCache) // db path -> open cache
+)
+
+// embeddingCache is a content-addressed store of chunk embeddings, keyed by
+// the hash of the embedding model and the chunk text
+type embeddingCache struct {
+	db   *bolt.DB
+	path string
+	refs int // open handles, guarded by embeddingCachesMu
+}
+
+// openEmbeddingCache opens (or creates) the cache database inside cacheDir.
+// Embedders in one process share a single handle per database, since bbolt
+// locks the file against a second open.
+func openEmbeddingCache(cacheDir string) (*embeddingCache, error) {
+	dbPath := filepath.Join(cacheDir, "embeddings.db")
+
+	embeddingCachesMu.Lock()
+	defer embeddingCachesMu.Unlock()
+
+	if cache, ok := embeddingCaches[dbPath]; ok {
+		cache.refs++
+		return cache, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(embeddingsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	cache := &embeddingCache{db: db, path: dbPath, refs: 1}
+	embeddingCaches[dbPath] = cache
+	return cache, nil
+}
+
+// embeddingCacheKey returns the content address of a chunk for a model
+func embeddingCacheKey(model, text string) string {
+	return utils.StringHash(model + text)
+}
+
+// Get looks up embeddings for the given keys; missing keys are absent from the result
+func (c *embeddingCache) Get(keys []string) (map[string][]float32, error) {
+	found := make(map[string][]float32)
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(embeddingsBucket)
+		for _, key := range keys {
+			if v := bucket.Get([]byte(key)); v != nil {
+				found[key] = decodeEmbedding(v)
+			}
+		}
+		return nil
+	})
+
+	return found, err
+}
+
+// Put stores embeddings by key
+func (c *embeddingCache) Put(entries map[string][]float32) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(embeddingsBucket)
+		for key, emb := range entries {
+			if err := bucket.Put([]byte(key), encodeEmbedding(emb)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close releases a handle, closing the database with the last one
+func (c *embeddingCache) Close() error {
+	embeddingCachesMu.Lock()
+	defer embeddingCachesMu.Unlock()
+
+	c.refs--
+	if c.refs > 0 {
+		return nil
+	}
+	delete(embeddingCaches, c.path)
+	return c.db.Close()
+}
+
+func encodeEmbedding(emb []float32) []byte {
+	buf := make([]byte, len(emb)*4)
+	for i, v := range emb {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeEmbedding(data []byte) []float32 {
+	emb := make([]float32, len(data)/4)
+	for i := range emb {
+		emb[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return emb
+}
@@ -0,0 +1,228 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+const (
+	defaultEmbeddingRetries = 3
+	defaultEmbeddingTimeout = 30 * time.Second
+	baseRetryDelay          = 500 * time.Millisecond
+	maxRetryDelay           = 30 * time.Second
+)
+
+// embeddingClient wraps the OpenAI embeddings API with rate limiting,
+// per-call timeouts and retries with exponential backoff
+type embeddingClient struct {
+	client     *openai.Client
+	tokenizer  Tokenizer
+	model      string
+	requests   *tokenBucket
+	tokens     *tokenBucket
+	maxRetries int
+	timeout    time.Duration
+}
+
+func newEmbeddingClient(cfg *config.Config, tokenizer Tokenizer) *embeddingClient {
+	embCfg := cfg.Context.Embeddings
+
+	clientConfig := openai.DefaultConfig(cfg.LLM.APIKey)
+	clientConfig.HTTPClient = &retryAfterRecorder{doer: &http.Client{}}
+
+	maxRetries := embCfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultEmbeddingRetries
+	}
+
+	timeout := time.Duration(embCfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultEmbeddingTimeout
+	}
+
+	return &embeddingClient{
+		client:     openai.NewClientWithConfig(clientConfig),
+		tokenizer:  tokenizer,
+		model:      embCfg.Model,
+		requests:   newTokenBucket(embCfg.RequestsPerMinute),
+		tokens:     newTokenBucket(embCfg.TokensPerMinute),
+		maxRetries: maxRetries,
+		timeout:    timeout,
+	}
+}
+
+// Embed returns one embedding per input text, in input order
+func (c *embeddingClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	logger := utils.GetLogger()
+	tokenCount := 0
+	for _, text := range texts {
+		tokenCount += c.tokenizer.Count(text)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.requests.Wait(ctx, 1); err != nil {
+			return nil, err
+		}
+		if err := c.tokens.Wait(ctx, tokenCount); err != nil {
+			return nil, err
+		}
+
+		embeddings, retryAfter, err := c.embedOnce(ctx, texts)
+		if err == nil {
+			return embeddings, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !isRetryableError(err) || attempt >= c.maxRetries {
+			return nil, err
+		}
+
+		delay := backoffDelay(attempt, retryAfter)
+		logger.Warnf("Embedding request failed (attempt %d/%d), retrying in %v: %v",
+			attempt+1, c.maxRetries+1, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *embeddingClient) embedOnce(ctx context.Context, texts []string) ([][]float32, time.Duration, error) {
+	hint := &retryHint{}
+	callCtx, cancel := context.WithTimeout(context.WithValue(ctx, retryHintKey{}, hint), c.timeout)
+	defer cancel()
+
+	resp, err := c.client.CreateEmbeddings(
+		callCtx,
+		openai.EmbeddingRequestStrings{
+			Input: texts,
+			Model: openai.EmbeddingModel(c.model),
+		},
+	)
+	if err != nil {
+		return nil, hint.after, err
+	}
+
+	if len(resp.Data) != len(texts) {
+		return nil, 0, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, 0, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+
+	return embeddings, 0, nil
+}
+
+// isRetryableError reports whether a failed call is worth retrying:
+// rate limits, server errors, timeouts and network failures
+func isRetryableError(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.HTTPStatusCode)
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return isRetryableStatus(reqErr.HTTPStatusCode)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// backoffDelay returns an exponential backoff with full jitter, never
+// shorter than the server-provided Retry-After
+func backoffDelay(attempt int, retryAfter time.Duration) time.Duration {
+	ceiling := baseRetryDelay << attempt
+	if ceiling <= 0 || ceiling > maxRetryDelay {
+		ceiling = maxRetryDelay
+	}
+
+	delay := time.Duration(rand.Int64N(int64(ceiling))) + baseRetryDelay/2
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	return delay
+}
+
+type retryHintKey struct{}
+
+// retryHint carries the Retry-After value of a failed response back to the caller
+type retryHint struct {
+	after time.Duration
+}
+
+// retryAfterRecorder captures Retry-After headers, which the OpenAI client
+// does not expose on its error types
+type retryAfterRecorder struct {
+	doer openai.HTTPDoer
+}
+
+func (r *retryAfterRecorder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := r.doer.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok && isRetryableStatus(resp.StatusCode) {
+		hint.after = parseRetryAfter(resp.Header)
+	}
+
+	return resp, nil
+}
+
+// parseRetryAfter reads retry-after-ms or Retry-After (seconds or HTTP date)
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms := header.Get("Retry-After-Ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v > 0 {
+			return time.Duration(v * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
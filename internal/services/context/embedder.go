@@ -4,55 +4,164 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"unicode"
+	"unicode/utf8"
 
-	openai "github.com/sashabaranov/go-openai"
 	"github.com/shashwatssp/deeprecall/internal/config"
 	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
 )
 
 type Embedder struct {
-	client *openai.Client
-	cfg    *config.Config
+	client    *embeddingClient
+	tokenizer Tokenizer
+	cfg       *config.Config
+	cacheOnce sync.Once
+	cache     *embeddingCache
 }
 
 func NewEmbedder(cfg *config.Config) *Embedder {
-	client := openai.NewClient(cfg.LLM.APIKey)
+	tokenizer := NewTokenizer(cfg)
 	return &Embedder{
-		client: client,
-		cfg:    cfg,
+		client:    newEmbeddingClient(cfg, tokenizer),
+		tokenizer: tokenizer,
+		cfg:       cfg,
 	}
 }
 
-// CreateEmbeddings generates embeddings for chunks
+// Tokenizer returns the tokenizer used to measure chunks
+func (e *Embedder) Tokenizer() Tokenizer {
+	return e.tokenizer
+}
+
+// CreateEmbeddings generates embeddings for chunks. Chunks that already carry
+// an embedding are skipped, so a call that failed part-way can be resumed by
+// passing the same chunks again.
 func (e *Embedder) CreateEmbeddings(chunks []*models.Chunk) error {
-	texts := make([]string, len(chunks))
-	for i, chunk := range chunks {
-		texts[i] = chunk.Content
+	var pending []*models.Chunk
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) == 0 {
+			pending = append(pending, chunk)
+		}
 	}
 
+	// Only chunks whose text has never been embedded with this model cost an API call
+	pending = e.fillFromCache(pending)
+	done := len(chunks) - len(pending)
+
 	// Process in batches
 	batchSize := e.cfg.Context.Embeddings.BatchSize
-	for i := 0; i < len(texts); i += batchSize {
+	for i := 0; i < len(pending); i += batchSize {
 		end := i + batchSize
-		if end > len(texts) {
-			end = len(texts)
+		if end > len(pending) {
+			end = len(pending)
+		}
+
+		batch := pending[i:end]
+		texts := make([]string, len(batch))
+		for j, chunk := range batch {
+			texts[j] = chunk.Content
 		}
 
-		batch := texts[i:end]
-		embeddings, err := e.getEmbeddings(batch)
+		embeddings, err := e.getEmbeddings(texts)
 		if err != nil {
-			return fmt.Errorf("failed to get embeddings: %w", err)
+			return fmt.Errorf("failed to get embeddings (%d/%d chunks embedded): %w", done+i, len(chunks), err)
 		}
 
 		for j, emb := range embeddings {
-			chunks[i+j].Embedding = emb
+			batch[j].Embedding = emb
 		}
+		e.storeInCache(batch)
 	}
 
 	return nil
 }
 
+// fillFromCache assigns cached embeddings and returns the chunks still missing one
+func (e *Embedder) fillFromCache(chunks []*models.Chunk) []*models.Chunk {
+	cache := e.embeddingCache()
+	if cache == nil || len(chunks) == 0 {
+		return chunks
+	}
+
+	model := e.cfg.Context.Embeddings.Model
+	keys := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		keys[i] = embeddingCacheKey(model, chunk.Content)
+	}
+
+	found, err := cache.Get(keys)
+	if err != nil {
+		utils.GetLogger().Warnf("Embedding cache lookup failed: %v", err)
+		return chunks
+	}
+
+	dimension := e.cfg.Context.Embeddings.Dimension
+	var missing []*models.Chunk
+	for i, chunk := range chunks {
+		emb, ok := found[keys[i]]
+		if !ok || (dimension > 0 && len(emb) != dimension) {
+			missing = append(missing, chunk)
+			continue
+		}
+		chunk.Embedding = emb
+	}
+
+	if hits := len(chunks) - len(missing); hits > 0 {
+		utils.GetLogger().Debugf("Embedding cache: %d hits, %d misses", hits, len(missing))
+	}
+
+	return missing
+}
+
+// storeInCache records freshly computed embeddings
+func (e *Embedder) storeInCache(chunks []*models.Chunk) {
+	cache := e.embeddingCache()
+	if cache == nil {
+		return
+	}
+
+	model := e.cfg.Context.Embeddings.Model
+	entries := make(map[string][]float32, len(chunks))
+	for _, chunk := range chunks {
+		entries[embeddingCacheKey(model, chunk.Content)] = chunk.Embedding
+	}
+
+	if err := cache.Put(entries); err != nil {
+		utils.GetLogger().Warnf("Failed to store embeddings in cache: %v", err)
+	}
+}
+
+// embeddingCache opens the chunk cache on first use. Opening lazily keeps
+// embedders that only embed queries from holding the database lock.
+func (e *Embedder) embeddingCache() *embeddingCache {
+	e.cacheOnce.Do(func() {
+		cacheDir := e.cfg.Context.Embeddings.CacheDir
+		if cacheDir == "" {
+			return
+		}
+
+		cache, err := openEmbeddingCache(cacheDir)
+		if err != nil {
+			utils.GetLogger().Warnf("Embedding cache disabled: %v", err)
+			return
+		}
+		e.cache = cache
+	})
+	return e.cache
+}
+
+// Close releases the embedding cache
+func (e *Embedder) Close() error {
+	// Wait for an open in flight, and keep later calls from opening the cache
+	e.cacheOnce.Do(func() {})
+	if e.cache == nil {
+		return nil
+	}
+	return e.cache.Close()
+}
+
 // CreateEmbedding creates a single embedding
 func (e *Embedder) CreateEmbedding(text string) ([]float32, error) {
 	embeddings, err := e.getEmbeddings([]string{text})
@@ -65,26 +174,22 @@ func (e *Embedder) CreateEmbedding(text string) ([]float32, error) {
 	return embeddings[0], nil
 }
 
-func (e *Embedder) getEmbeddings(texts []string) ([][]float32, error) {
-	resp, err := e.client.CreateEmbeddings(
-		context.Background(),
-		openai.EmbeddingRequestStrings{
-			Input: texts,
-			Model: openai.EmbeddingModel(e.cfg.Context.Embeddings.Model),
-		},
-	)
+// EmbedTexts creates embeddings for several texts in one request
+func (e *Embedder) EmbedTexts(texts []string) ([][]float32, error) {
+	embeddings, err := e.getEmbeddings(texts)
 	if err != nil {
 		return nil, err
 	}
-
-	embeddings := make([][]float32, len(resp.Data))
-	for i, data := range resp.Data {
-		embeddings[i] = data.Embedding
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(embeddings), len(texts))
 	}
-
 	return embeddings, nil
 }
 
+func (e *Embedder) getEmbeddings(texts []string) ([][]float32, error) {
+	return e.client.Embed(context.Background(), texts)
+}
+
 // ChunkDocument splits a document into chunks
 func (e *Embedder) ChunkDocument(doc *models.Document) []*models.Chunk {
 	cfg := e.cfg.Context.Chunking
@@ -92,166 +197,279 @@ func (e *Embedder) ChunkDocument(doc *models.Document) []*models.Chunk {
 
 	var chunks []*models.Chunk
 
-	switch cfg.Method {
-	case "fixed":
+	switch {
+	case isTabular(doc):
+		chunks = e.chunkRows(doc, text, cfg.ChunkSize)
+	case isTranscript(doc):
+		chunks = e.chunkTranscript(doc, text, cfg.ChunkSize)
+	case isEmail(doc):
+		chunks = e.chunkEmail(doc, text, cfg.ChunkSize, cfg.ChunkOverlap)
+	case isMarkdown(doc):
+		chunks = e.chunkMarkdown(doc, text, cfg.ChunkSize, cfg.ChunkOverlap, cfg.MinChunkSize)
+	case isSourceCode(doc):
+		chunks = e.chunkCode(doc, text, cfg.ChunkSize)
+	case cfg.Method == "fixed":
 		chunks = e.chunkFixed(doc, text, cfg.ChunkSize, cfg.ChunkOverlap)
-	case "recursive":
+	case cfg.Method == "recursive":
 		chunks = e.chunkRecursive(doc, text, cfg.ChunkSize, cfg.ChunkOverlap)
+	case cfg.Method == "semantic":
+		chunks = e.chunkSemantic(doc, text, cfg.ChunkSize, cfg.MinChunkSize)
 	default:
+		// Unreachable with a validated config
+		utils.GetLogger().Warnf("Unknown chunking method %q, using fixed", cfg.Method)
 		chunks = e.chunkFixed(doc, text, cfg.ChunkSize, cfg.ChunkOverlap)
 	}
 
 	// Filter out chunks that are too small
 	filtered := make([]*models.Chunk, 0)
 	for _, chunk := range chunks {
-		if len(strings.TrimSpace(chunk.Content)) >= cfg.MinChunkSize {
+		if e.tokenizer.Count(chunk.Content) >= cfg.MinChunkSize {
 			filtered = append(filtered, chunk)
 		}
 	}
 
+	if len(doc.PageOffsets) > 0 {
+		annotatePages(doc, filtered)
+	}
+	if len(doc.OCRConfidence) > 0 {
+		annotateOCR(doc, filtered)
+	}
+
 	return filtered
 }
 
 func (e *Embedder) chunkFixed(doc *models.Document, text string, size, overlap int) []*models.Chunk {
 	var chunks []*models.Chunk
-	runes := []rune(text)
-
-	for i := 0; i < len(runes); i += (size - overlap) {
-		end := i + size
-		if end > len(runes) {
-			end = len(runes)
-		}
-
-		content := string(runes[i:end])
-		chunk := &models.Chunk{
-			DocumentID: doc.ID,
-			Content:    strings.TrimSpace(content),
-			Index:      len(chunks),
-			Metadata: map[string]interface{}{
-				"source":    doc.FilePath,
-				"doc_id":    doc.ID,
-				"chunk_idx": len(chunks),
-			},
-		}
+	for _, span := range e.tokenSpans(text, size, overlap) {
+		chunk := newChunk(doc, text[span[0]:span[1]], len(chunks))
+		setSpan(chunk, text, span[0], span[1])
 		chunks = append(chunks, chunk)
-
-		if end >= len(runes) {
-			break
-		}
 	}
-
 	return chunks
 }
 
 func (e *Embedder) chunkRecursive(doc *models.Document, text string, size, overlap int) []*models.Chunk {
-	// Split by paragraphs first
-	paragraphs := strings.Split(text, "\n\n")
-
 	var chunks []*models.Chunk
 	var currentChunk strings.Builder
-	chunkIdx := 0
+	currentTokens := 0
+	// Byte range in text of what the current chunk was built from
+	spanStart, spanEnd := -1, -1
+
+	extend := func(start, end int) {
+		if spanStart < 0 {
+			spanStart = start
+		}
+		spanEnd = end
+	}
 
-	for _, para := range paragraphs {
-		para = strings.TrimSpace(para)
+	flush := func() {
+		chunk := newChunk(doc, currentChunk.String(), len(chunks))
+		if spanStart >= 0 {
+			setSpan(chunk, text, spanStart, spanEnd)
+		}
+		chunks = append(chunks, chunk)
+		currentChunk.Reset()
+		currentTokens = 0
+		spanStart, spanEnd = -1, -1
+	}
+
+	// Split by paragraphs first
+	offset := 0
+	for _, raw := range strings.Split(text, "\n\n") {
+		paraStart := offset
+		offset += len(raw) + len("\n\n")
+
+		para := strings.TrimSpace(raw)
 		if para == "" {
 			continue
 		}
+		paraStart += strings.Index(raw, para)
+
+		paraTokens := e.tokenizer.Count(para)
 
 		// If paragraph itself is too large, split it
-		if len(para) > size {
-			sentences := splitSentences(para)
-			for _, sent := range sentences {
-				if currentChunk.Len()+len(sent) > size {
+		if paraTokens > size {
+			for _, span := range sentenceSpans(para) {
+				sent := para[span[0]:span[1]]
+				sentTokens := e.tokenizer.Count(sent)
+				sentStart, sentEnd := paraStart+span[0], paraStart+span[1]
+
+				if currentTokens+sentTokens > size && currentChunk.Len() > 0 {
+					// Keep overlap
+					tail := e.overlapTail(currentChunk.String(), overlap)
+					tailStart := max(spanEnd-len(e.overlapTail(text[spanStart:spanEnd], overlap)), spanStart)
+					flush()
+					if tail != "" {
+						currentChunk.WriteString(tail)
+						currentChunk.WriteString(" ")
+						currentTokens = e.tokenizer.Count(tail)
+						extend(tailStart, tailStart)
+					}
+				}
+
+				// A single sentence over the limit is split into token windows
+				if sentTokens > size {
 					if currentChunk.Len() > 0 {
-						chunk := &models.Chunk{
-							DocumentID: doc.ID,
-							Content:    strings.TrimSpace(currentChunk.String()),
-							Index:      chunkIdx,
-							Metadata: map[string]interface{}{
-								"source":    doc.FilePath,
-								"doc_id":    doc.ID,
-								"chunk_idx": chunkIdx,
-							},
-						}
+						flush()
+					}
+					for _, span := range e.tokenSpans(sent, size, overlap) {
+						chunk := newChunk(doc, sent[span[0]:span[1]], len(chunks))
+						setSpan(chunk, text, sentStart+span[0], sentStart+span[1])
 						chunks = append(chunks, chunk)
-						chunkIdx++
-
-						// Keep overlap
-						words := strings.Fields(currentChunk.String())
-						overlapWords := overlap / 10
-						if overlapWords > len(words) {
-							overlapWords = len(words)
-						}
-						currentChunk.Reset()
-						if overlapWords > 0 {
-							currentChunk.WriteString(strings.Join(words[len(words)-overlapWords:], " "))
-							currentChunk.WriteString(" ")
-						}
 					}
+					continue
 				}
+
 				currentChunk.WriteString(sent)
 				currentChunk.WriteString(" ")
+				currentTokens += sentTokens
+				extend(sentStart, sentEnd)
 			}
 		} else {
-			if currentChunk.Len()+len(para) > size {
-				if currentChunk.Len() > 0 {
-					chunk := &models.Chunk{
-						DocumentID: doc.ID,
-						Content:    strings.TrimSpace(currentChunk.String()),
-						Index:      chunkIdx,
-						Metadata: map[string]interface{}{
-							"source":    doc.FilePath,
-							"doc_id":    doc.ID,
-							"chunk_idx": chunkIdx,
-						},
-					}
-					chunks = append(chunks, chunk)
-					chunkIdx++
-					currentChunk.Reset()
-				}
+			if currentTokens+paraTokens > size && currentChunk.Len() > 0 {
+				flush()
 			}
 			currentChunk.WriteString(para)
 			currentChunk.WriteString("\n\n")
+			currentTokens += paraTokens
+			extend(paraStart, paraStart+len(para))
 		}
 	}
 
 	// Add remaining chunk
-	if currentChunk.Len() > 0 {
-		chunk := &models.Chunk{
-			DocumentID: doc.ID,
-			Content:    strings.TrimSpace(currentChunk.String()),
-			Index:      chunkIdx,
-			Metadata: map[string]interface{}{
-				"source":    doc.FilePath,
-				"doc_id":    doc.ID,
-				"chunk_idx": chunkIdx,
-			},
-		}
-		chunks = append(chunks, chunk)
+	if strings.TrimSpace(currentChunk.String()) != "" {
+		flush()
 	}
 
 	return chunks
 }
 
-func splitSentences(text string) []string {
-	var sentences []string
-	var current strings.Builder
+// splitByTokens cuts text into windows of at most size tokens, consecutive
+// windows sharing up to overlap tokens. Windows end on pre-token boundaries
+// so words and multi-byte characters are never split.
+func (e *Embedder) splitByTokens(text string, size, overlap int) []string {
+	var windows []string
+	for _, span := range e.tokenSpans(text, size, overlap) {
+		windows = append(windows, text[span[0]:span[1]])
+	}
+	return windows
+}
 
-	runes := []rune(text)
-	for i := 0; i < len(runes); i++ {
-		current.WriteRune(runes[i])
+// tokenSpans returns the byte ranges in text of the windows splitByTokens
+// cuts it into
+func (e *Embedder) tokenSpans(text string, size, overlap int) [][2]int {
+	pieces := pretokenize(text)
 
-		if runes[i] == '.' || runes[i] == '!' || runes[i] == '?' {
-			if i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
-				sentences = append(sentences, strings.TrimSpace(current.String()))
-				current.Reset()
-			}
+	// pretokenize works on runes, an invalid byte being one rune
+	runeOffsets := make([]int, 0, len(text)+1)
+	for i := range text {
+		runeOffsets = append(runeOffsets, i)
+	}
+	runeOffsets = append(runeOffsets, len(text))
+
+	counts := make([]int, len(pieces))
+	offsets := make([]int, len(pieces)+1) // byte offset of each piece
+	runes := 0
+	for i, piece := range pieces {
+		counts[i] = e.tokenizer.Count(piece)
+		runes += utf8.RuneCountInString(piece)
+		offsets[i+1] = runeOffsets[runes]
+	}
+
+	var spans [][2]int
+	for start := 0; start < len(pieces); {
+		end, tokens := start, 0
+		for end < len(pieces) && (end == start || tokens+counts[end] <= size) {
+			tokens += counts[end]
+			end++
+		}
+
+		spans = append(spans, [2]int{offsets[start], offsets[end]})
+		if end >= len(pieces) {
+			break
+		}
+
+		// Step back so the next window repeats the last overlap tokens
+		next, kept := end, 0
+		for next > start+1 && kept+counts[next-1] <= overlap {
+			next--
+			kept += counts[next]
+		}
+		start = next
+	}
+
+	return spans
+}
+
+// overlapTail returns the end of text spanning at most overlap tokens
+func (e *Embedder) overlapTail(text string, overlap int) string {
+	pieces := pretokenize(strings.TrimSpace(text))
+
+	start, kept := len(pieces), 0
+	for start > 0 {
+		count := e.tokenizer.Count(pieces[start-1])
+		if kept+count > overlap {
+			break
+		}
+		kept += count
+		start--
+	}
+
+	return strings.TrimSpace(strings.Join(pieces[start:], ""))
+}
+
+// newChunk creates a chunk of doc with the standard metadata
+func newChunk(doc *models.Document, content string, index int) *models.Chunk {
+	return &models.Chunk{
+		DocumentID: doc.ID,
+		Content:    strings.TrimSpace(content),
+		Index:      index,
+		Metadata: map[string]interface{}{
+			"source":    doc.FilePath,
+			"doc_id":    doc.ID,
+			"chunk_idx": index,
+		},
+	}
+}
+
+// setSpan records the byte range of the document text a chunk was cut
+// from, without surrounding whitespace, so pages can be mapped from it
+func setSpan(chunk *models.Chunk, text string, start, end int) {
+	span := text[start:end]
+	start += len(span) - len(strings.TrimLeftFunc(span, unicode.IsSpace))
+	end -= len(span) - len(strings.TrimRightFunc(span, unicode.IsSpace))
+
+	chunk.Metadata["offset"] = start
+	chunk.Metadata["offset_end"] = max(start, end)
+}
+
+// sentenceSpans returns the byte ranges of the sentences in text, without
+// surrounding whitespace. A sentence ends at '.', '!' or '?' followed by
+// whitespace.
+func sentenceSpans(text string) [][2]int {
+	var spans [][2]int
+	add := func(start, end int) {
+		sent := text[start:end]
+		start += len(sent) - len(strings.TrimLeftFunc(sent, unicode.IsSpace))
+		end -= len(sent) - len(strings.TrimRightFunc(sent, unicode.IsSpace))
+		if start < end {
+			spans = append(spans, [2]int{start, end})
 		}
 	}
 
-	if current.Len() > 0 {
-		sentences = append(sentences, strings.TrimSpace(current.String()))
+	start := 0
+	for i := 0; i < len(text); {
+		r, width := utf8.DecodeRuneInString(text[i:])
+		i += width
+
+		if r == '.' || r == '!' || r == '?' {
+			if next, _ := utf8.DecodeRuneInString(text[i:]); i < len(text) && unicode.IsSpace(next) {
+				add(start, i)
+				start = i
+			}
+		}
 	}
+	add(start, len(text))
 
-	return sentences
+	return spans
 }
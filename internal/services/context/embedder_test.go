@@ -0,0 +1,63 @@
+package context
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+)
+
+// A Latin-1 text file is valid input; chunk offsets must stay inside the
+// content however its bytes decode
+func TestChunkLatin1Text(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	latin1 := strings.Repeat("Caf\xe9 cr\xe8me br\xfbl\xe9e is on the menu. ", 40)
+	if err := os.WriteFile(path, []byte(latin1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Context.Chunking.Method = "recursive"
+	cfg.Context.Chunking.ChunkSize = 50
+	cfg.Context.Chunking.ChunkOverlap = 10
+	cfg.Context.Chunking.MinChunkSize = 1
+	e := &Embedder{tokenizer: &approxTokenizer{}, cfg: cfg}
+
+	doc, err := NewParser().ParseDocument(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.ValidString(doc.Content) || !strings.Contains(doc.Content, "Café crème brûlée") {
+		t.Fatalf("content not decoded as Latin-1: %q", doc.Content[:40])
+	}
+
+	chunks := e.ChunkDocument(doc)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for _, chunk := range chunks {
+		start, _ := chunk.Metadata["offset"].(int)
+		end, _ := chunk.Metadata["offset_end"].(int)
+		if start < 0 || end > len(doc.Content) || start >= end {
+			t.Errorf("chunk %d has span [%d, %d) in %d bytes", chunk.Index, start, end, len(doc.Content))
+		}
+	}
+}
+
+func TestSentenceSpansInvalidUTF8(t *testing.T) {
+	text := "Caf\xe9 one.  Second \xff sentence!\nThird"
+	want := []string{"Caf\xe9 one.", "Second \xff sentence!", "Third"}
+
+	spans := sentenceSpans(text)
+	if len(spans) != len(want) {
+		t.Fatalf("got %d sentences, want %d", len(spans), len(want))
+	}
+	for i, span := range spans {
+		if got := text[span[0]:span[1]]; got != want[i] {
+			t.Errorf("sentence %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package context
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// epubContainer is META-INF/container.xml, which points at the package document
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the OPF package document: metadata, manifest and reading order
+type epubPackage struct {
+	Metadata struct {
+		Title       []string `xml:"title"`
+		Creator     []string `xml:"creator"`
+		Date        []string `xml:"date"`
+		Publisher   []string `xml:"publisher"`
+		Description []string `xml:"description"`
+		Identifier  []string `xml:"identifier"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// parseEPUB extracts the text of an EPUB book, reading its chapters in
+// spine order
+func parseEPUB(filePath string, doc *models.Document) error {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer zr.Close()
+
+	data, err := readZipEntry(&zr.Reader, "META-INF/container.xml")
+	if err != nil {
+		return fmt.Errorf("failed to read EPUB container: %w", err)
+	}
+	var container epubContainer
+	if err := xml.Unmarshal(data, &container); err != nil || len(container.Rootfiles) == 0 {
+		return fmt.Errorf("failed to read EPUB container: no package document")
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	data, err = readZipEntry(&zr.Reader, opfPath)
+	if err != nil {
+		return fmt.Errorf("failed to read EPUB package: %w", err)
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return fmt.Errorf("failed to parse EPUB package: %w", err)
+	}
+
+	type manifestItem struct {
+		href, mediaType, properties string
+	}
+	items := make(map[string]manifestItem, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		items[item.ID] = manifestItem{item.Href, item.MediaType, item.Properties}
+	}
+
+	var content strings.Builder
+	for _, ref := range pkg.Spine {
+		item, ok := items[ref.IDRef]
+		if !ok || !strings.Contains(item.mediaType, "html") {
+			continue
+		}
+		// The navigation document repeats the table of contents
+		if strings.Contains(item.properties, "nav") {
+			continue
+		}
+
+		chapterPath := epubPath(opfPath, item.href)
+		chapter, err := readZipEntry(&zr.Reader, chapterPath)
+		if err != nil {
+			utils.GetLogger().Warnf("Failed to read EPUB chapter %s: %v", chapterPath, err)
+			continue
+		}
+
+		text, _ := htmlToMarkdown(decodeText(chapter))
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		content.WriteString(text)
+		content.WriteString("\n")
+	}
+
+	doc.Content = content.String()
+	doc.Metadata["content_format"] = "markdown"
+
+	meta := map[string]string{
+		"title":       firstNonEmpty(pkg.Metadata.Title),
+		"author":      strings.Join(pkg.Metadata.Creator, ", "),
+		"publisher":   firstNonEmpty(pkg.Metadata.Publisher),
+		"description": firstNonEmpty(pkg.Metadata.Description),
+		"identifier":  firstNonEmpty(pkg.Metadata.Identifier),
+	}
+	if date := firstNonEmpty(pkg.Metadata.Date); date != "" {
+		meta["published"] = normalizeDate(date)
+	}
+	setMetadata(doc.Metadata, meta)
+
+	return nil
+}
+
+// epubPath resolves a manifest href against the package document's directory
+func epubPath(opfPath, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(opfPath), href)
+}
+
+func firstNonEmpty(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,761 @@
+package context
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+type htmlTokenKind int
+
+const (
+	htmlText htmlTokenKind = iota
+	htmlStartTag
+	htmlEndTag
+	htmlComment
+)
+
+// htmlToken is a tag, text run or comment
+type htmlToken struct {
+	kind  htmlTokenKind
+	name  string // lower-case tag name
+	attrs map[string]string
+	text  string
+}
+
+// htmlVoidElements never have end tags
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawTextElements contain text that is not parsed for tags
+var htmlRawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true, "xmp": true,
+}
+
+// htmlBoilerplateElements are dropped with everything inside them
+var htmlBoilerplateElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true, "nav": true,
+	"aside": true, "footer": true, "form": true, "iframe": true, "svg": true,
+	"button": true, "select": true, "textarea": true, "dialog": true, "object": true,
+}
+
+// htmlBlockElements end the current paragraph
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "header": true,
+	"blockquote": true, "figure": true, "figcaption": true, "dl": true, "dt": true, "dd": true,
+	"address": true, "details": true, "summary": true, "hr": true, "center": true, "body": true,
+}
+
+// htmlImplicitClose lists, for elements whose end tag is optional, the open
+// elements a new start tag closes and the elements that stop the search
+var htmlImplicitClose = map[string]struct{ closes, stops []string }{
+	"li": {[]string{"li"}, []string{"ul", "ol", "menu"}},
+	"dt": {[]string{"dt", "dd"}, []string{"dl"}},
+	"dd": {[]string{"dt", "dd"}, []string{"dl"}},
+	"tr": {[]string{"tr"}, []string{"table", "thead", "tbody", "tfoot"}},
+	"td": {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th": {[]string{"td", "th"}, []string{"tr", "table"}},
+	"p":  {[]string{"p"}, []string{"div", "li", "td", "th", "section", "article", "main", "blockquote", "body"}},
+}
+
+var (
+	// htmlBoilerplateRe matches id, class and role values of navigation and page chrome
+	htmlBoilerplateRe = regexp.MustCompile(`(?i)(^|[\s_-])(nav|navbar|navigation|menu|sidebar|breadcrumbs?|cookies?|banner|advert\w*|ads|share|social|comments?|related|newsletter|subscribe|popup|modal|skip-link|site-footer|site-header|contentinfo|complementary)($|[\s_-])`)
+	savedFromRe       = regexp.MustCompile(`saved from url=\(\d+\)(\S+)`)
+	headingTagRe      = regexp.MustCompile(`^h([1-6])$`)
+)
+
+// parseHTML extracts the main text of an HTML page as Markdown, dropping
+// scripts, navigation and other page chrome
+func parseHTML(filePath string, doc *models.Document) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	content, meta := htmlToMarkdown(decodeText(data))
+
+	doc.Content = content
+	doc.Metadata["content_format"] = "markdown"
+	setMetadata(doc.Metadata, meta)
+
+	return nil
+}
+
+// parseMHTML extracts the HTML part of a web page saved as a single
+// MIME archive (.mht, .mhtml)
+func parseMHTML(filePath string, doc *models.Document) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	msg, err := mail.ReadMessage(file)
+	if err != nil {
+		return fmt.Errorf("failed to read MHTML: %w", err)
+	}
+
+	page, location, err := mhtmlPage(msg.Header.Get("Content-Type"), msg.Header, msg.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read MHTML: %w", err)
+	}
+
+	content, meta := htmlToMarkdown(decodeText(page))
+	if meta["canonical_url"] == "" {
+		meta["canonical_url"] = location
+	}
+
+	doc.Content = content
+	doc.Metadata["content_format"] = "markdown"
+	setMetadata(doc.Metadata, meta)
+
+	return nil
+}
+
+// mhtmlPage returns the first text/html body in a MIME archive and its
+// Content-Location
+func mhtmlPage(contentType string, header interface{ Get(string) string }, body io.Reader) ([]byte, string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		if mediaType != "text/html" {
+			return nil, "", fmt.Errorf("no HTML part found")
+		}
+		data, err := decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+		return data, header.Get("Content-Location"), err
+	}
+
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return nil, "", fmt.Errorf("no HTML part found")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		data, location, err := mhtmlPage(part.Header.Get("Content-Type"), part.Header, part)
+		if err == nil {
+			return data, location, nil
+		}
+	}
+}
+
+// decodeTransfer decodes a MIME part body according to its
+// Content-Transfer-Encoding
+func decodeTransfer(encoding string, body io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, newlineStripper{body})
+	}
+	return io.ReadAll(io.LimitReader(body, maxArchiveEntrySize))
+}
+
+// newlineStripper drops line breaks from wrapped base64 text
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	count, err := n.r.Read(p)
+	kept := 0
+	for _, b := range p[:count] {
+		if b != '\r' && b != '\n' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+// htmlToMarkdown renders the main content of an HTML page as Markdown and
+// returns the page properties (title, canonical URL, author, ...)
+func htmlToMarkdown(src string) (string, map[string]string) {
+	tokens := tokenizeHTML(src)
+	meta := htmlMetadata(tokens)
+
+	start, end := htmlContentRange(tokens)
+	r := &htmlRenderer{}
+	for _, tok := range tokens[start:end] {
+		r.token(tok)
+	}
+	r.closeTo(0)
+	r.flush()
+	r.out.EndTable()
+
+	return r.out.String(), meta
+}
+
+// htmlMetadata collects <title>, canonical URL and <meta> properties
+func htmlMetadata(tokens []htmlToken) map[string]string {
+	meta := make(map[string]string)
+	props := make(map[string]string)
+	inBody := false
+
+	for i, tok := range tokens {
+		switch tok.kind {
+		case htmlComment:
+			if m := savedFromRe.FindStringSubmatch(tok.text); m != nil && props["saved_from"] == "" {
+				props["saved_from"] = m[1]
+			}
+		case htmlStartTag:
+			switch tok.name {
+			case "body":
+				inBody = true
+			case "title":
+				// <title> inside inline SVG in the body is not the page title
+				if !inBody && meta["title"] == "" && i+1 < len(tokens) && tokens[i+1].kind == htmlText {
+					meta["title"] = collapseSpace(tokens[i+1].text)
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(tok.attrs["rel"])) {
+					if rel == "canonical" && meta["canonical_url"] == "" {
+						meta["canonical_url"] = strings.TrimSpace(tok.attrs["href"])
+					}
+				}
+			case "meta":
+				key := strings.ToLower(tok.attrs["name"])
+				if key == "" {
+					key = strings.ToLower(tok.attrs["property"])
+				}
+				if key != "" && props[key] == "" {
+					props[key] = strings.TrimSpace(tok.attrs["content"])
+				}
+			}
+		}
+	}
+
+	fallbacks := []struct {
+		key     string
+		sources []string
+	}{
+		{"title", []string{"og:title", "twitter:title"}},
+		{"canonical_url", []string{"og:url", "saved_from"}},
+		{"author", []string{"author", "article:author", "dc.creator"}},
+		{"description", []string{"description", "og:description"}},
+		{"keywords", []string{"keywords"}},
+		{"published", []string{"article:published_time", "date", "dc.date", "pubdate"}},
+	}
+	for _, f := range fallbacks {
+		for _, source := range f.sources {
+			if meta[f.key] == "" {
+				meta[f.key] = props[source]
+			}
+		}
+	}
+	if meta["published"] != "" {
+		meta["published"] = normalizeDate(meta["published"])
+	}
+
+	return meta
+}
+
+// htmlContentRange returns the token range holding the page's main content:
+// its only <article>, else its <main>, else the whole page
+func htmlContentRange(tokens []htmlToken) (int, int) {
+	articles, main := 0, -1
+	firstArticle := -1
+	for i, tok := range tokens {
+		if tok.kind != htmlStartTag {
+			continue
+		}
+		switch tok.name {
+		case "article":
+			if firstArticle < 0 {
+				firstArticle = i
+			}
+			articles++
+		case "main":
+			if main < 0 {
+				main = i
+			}
+		}
+	}
+
+	start := -1
+	switch {
+	case articles == 1:
+		start = firstArticle
+	case main >= 0:
+		start = main
+	default:
+		return 0, len(tokens)
+	}
+
+	name := tokens[start].name
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		if tokens[i].name != name {
+			continue
+		}
+		switch tokens[i].kind {
+		case htmlStartTag:
+			depth++
+		case htmlEndTag:
+			depth--
+			if depth == 0 {
+				return start, i + 1
+			}
+		}
+	}
+	return start, len(tokens)
+}
+
+// htmlRenderer turns a token stream into Markdown
+type htmlRenderer struct {
+	out   markdownWriter
+	stack []string
+	skip  int // stack depth at which skipped content started, 0 if not skipping
+
+	para    strings.Builder
+	heading int
+	lists   int
+	items   int
+	pre     int
+	tables  int
+	cells   int
+	row     []string
+	cell    strings.Builder
+}
+
+func (r *htmlRenderer) token(tok htmlToken) {
+	switch tok.kind {
+	case htmlText:
+		if r.skip > 0 {
+			return
+		}
+		if r.pre > 0 {
+			r.para.WriteString(tok.text)
+			return
+		}
+		r.writeInline(tok.text)
+
+	case htmlStartTag:
+		if r.skip > 0 {
+			if !htmlVoidElements[tok.name] {
+				r.stack = append(r.stack, tok.name)
+			}
+			return
+		}
+
+		if rule, ok := htmlImplicitClose[tok.name]; ok {
+			r.implicitClose(rule.closes, rule.stops)
+		}
+
+		if r.isBoilerplate(tok) {
+			if !htmlVoidElements[tok.name] {
+				r.stack = append(r.stack, tok.name)
+				r.skip = len(r.stack)
+			}
+			return
+		}
+
+		r.open(tok.name)
+		if !htmlVoidElements[tok.name] {
+			r.stack = append(r.stack, tok.name)
+		}
+
+	case htmlEndTag:
+		for i := len(r.stack) - 1; i >= 0; i-- {
+			if r.stack[i] == tok.name {
+				r.closeTo(i)
+				return
+			}
+		}
+	}
+}
+
+// isBoilerplate reports whether an element and its content should be dropped
+func (r *htmlRenderer) isBoilerplate(tok htmlToken) bool {
+	if htmlBoilerplateElements[tok.name] {
+		return true
+	}
+	if _, hidden := tok.attrs["hidden"]; hidden || tok.attrs["aria-hidden"] == "true" {
+		return true
+	}
+	if strings.Contains(strings.ReplaceAll(tok.attrs["style"], " ", ""), "display:none") {
+		return true
+	}
+	// A page header is chrome, but an article's header holds its title
+	if tok.name == "header" && !r.inside("article", "main") {
+		return true
+	}
+	// Page-level class names ("nav-open") say nothing about the content
+	if tok.name == "html" || tok.name == "body" || tok.name == "main" || tok.name == "article" {
+		return false
+	}
+	for _, attr := range []string{"id", "class", "role"} {
+		if htmlBoilerplateRe.MatchString(tok.attrs[attr]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *htmlRenderer) inside(names ...string) bool {
+	for _, open := range r.stack {
+		for _, name := range names {
+			if open == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// implicitClose closes an open element whose end tag was omitted
+func (r *htmlRenderer) implicitClose(closes, stops []string) {
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		for _, stop := range stops {
+			if r.stack[i] == stop {
+				return
+			}
+		}
+		for _, name := range closes {
+			if r.stack[i] == name {
+				r.closeTo(i)
+				return
+			}
+		}
+	}
+}
+
+// open applies the effect of a start tag
+func (r *htmlRenderer) open(name string) {
+	if m := headingTagRe.FindStringSubmatch(name); m != nil {
+		r.flush()
+		r.heading = int(m[1][0] - '0')
+		return
+	}
+
+	switch name {
+	case "br":
+		r.para.WriteString("\n")
+	case "ul", "ol", "menu":
+		r.flush()
+		r.lists++
+	case "li":
+		r.flush()
+		r.items++
+	case "pre":
+		r.flush()
+		r.pre++
+	case "table":
+		r.flush()
+		r.tables++
+	case "tr":
+		r.row = nil
+	case "td", "th":
+		r.flush()
+		r.cells++
+		r.cell.Reset()
+	default:
+		if htmlBlockElements[name] {
+			r.flush()
+		}
+	}
+}
+
+// closeTo closes the elements on the stack from the top down to index i
+func (r *htmlRenderer) closeTo(i int) {
+	for len(r.stack) > i {
+		name := r.stack[len(r.stack)-1]
+		r.stack = r.stack[:len(r.stack)-1]
+
+		if r.skip > 0 {
+			if len(r.stack) < r.skip {
+				r.skip = 0
+			}
+			continue
+		}
+		r.close(name)
+	}
+}
+
+// close applies the effect of an end tag
+func (r *htmlRenderer) close(name string) {
+	if headingTagRe.MatchString(name) {
+		r.flush()
+		r.heading = 0
+		return
+	}
+
+	switch name {
+	case "ul", "ol", "menu":
+		r.flush()
+		r.lists--
+	case "li":
+		r.flush()
+		r.items--
+	case "pre":
+		if r.pre--; r.pre == 0 {
+			r.out.CodeBlock(r.para.String())
+			r.para.Reset()
+		}
+	case "td", "th":
+		r.flush()
+		r.cells--
+		r.row = append(r.row, r.cell.String())
+		r.cell.Reset()
+	case "tr":
+		if r.tables == 1 {
+			r.out.TableRow(r.row)
+		}
+		r.row = nil
+	case "table":
+		r.flush()
+		if r.tables--; r.tables == 0 {
+			r.out.EndTable()
+		}
+	default:
+		if htmlBlockElements[name] {
+			r.flush()
+		}
+	}
+}
+
+// writeInline appends text with whitespace runs collapsed to one space
+func (r *htmlRenderer) writeInline(text string) {
+	if text == "" {
+		return
+	}
+	if isHTMLSpace(text[0]) {
+		r.para.WriteString(" ")
+	}
+	r.para.WriteString(strings.Join(strings.Fields(text), " "))
+	if isHTMLSpace(text[len(text)-1]) {
+		r.para.WriteString(" ")
+	}
+}
+
+// flush writes the pending inline text as a heading, list item, table cell
+// or paragraph
+func (r *htmlRenderer) flush() {
+	if r.pre > 0 {
+		return
+	}
+
+	var lines []string
+	for _, line := range strings.Split(r.para.String(), "\n") {
+		if line = collapseSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	r.para.Reset()
+	text := strings.Join(lines, "\n")
+	if text == "" {
+		return
+	}
+
+	switch {
+	case r.cells > 0:
+		if r.cell.Len() > 0 {
+			r.cell.WriteString(" ")
+		}
+		r.cell.WriteString(text)
+	case r.heading > 0:
+		r.out.Heading(r.heading, text)
+	case r.items > 0:
+		depth := r.lists - 1
+		if depth < 0 {
+			depth = 0
+		}
+		r.out.ListItem(text, depth)
+	default:
+		r.out.Paragraph(text)
+	}
+}
+
+// tokenizeHTML splits an HTML document into tags, text and comments. It is
+// lenient: malformed markup is passed through as text.
+func tokenizeHTML(src string) []htmlToken {
+	var tokens []htmlToken
+
+	text := func(s string) {
+		if s != "" {
+			tokens = append(tokens, htmlToken{kind: htmlText, text: html.UnescapeString(s)})
+		}
+	}
+
+	for i := 0; i < len(src); {
+		lt := strings.IndexByte(src[i:], '<')
+		if lt < 0 {
+			text(src[i:])
+			break
+		}
+		text(src[i : i+lt])
+		i += lt
+
+		switch {
+		case strings.HasPrefix(src[i:], "<!--"):
+			end := strings.Index(src[i+4:], "-->")
+			if end < 0 {
+				return tokens
+			}
+			tokens = append(tokens, htmlToken{kind: htmlComment, text: src[i+4 : i+4+end]})
+			i += 4 + end + 3
+
+		case strings.HasPrefix(src[i:], "<![CDATA["):
+			end := strings.Index(src[i:], "]]>")
+			if end < 0 {
+				return tokens
+			}
+			tokens = append(tokens, htmlToken{kind: htmlText, text: src[i+9 : i+end]})
+			i += end + 3
+
+		case strings.HasPrefix(src[i:], "<!") || strings.HasPrefix(src[i:], "<?"):
+			end := strings.IndexByte(src[i:], '>')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+
+		case strings.HasPrefix(src[i:], "</"):
+			end := strings.IndexByte(src[i:], '>')
+			if end < 0 {
+				return tokens
+			}
+			// Stray "</>" and "</ >" close nothing and are dropped
+			if fields := strings.Fields(src[i+2 : i+end]); len(fields) > 0 {
+				tokens = append(tokens, htmlToken{kind: htmlEndTag, name: strings.ToLower(fields[0])})
+			}
+			i += end + 1
+
+		case i+1 < len(src) && isASCIILetter(src[i+1]):
+			tok, next := parseHTMLTag(src, i)
+			tokens = append(tokens, tok)
+			i = next
+
+			if htmlRawTextElements[tok.name] {
+				end := indexASCIIFold(src[i:], "</"+tok.name)
+				if end < 0 {
+					end = len(src) - i
+				}
+				raw := src[i : i+end]
+				if tok.name == "script" || tok.name == "style" {
+					tokens = append(tokens, htmlToken{kind: htmlText, text: raw})
+				} else {
+					text(raw)
+				}
+				i += end
+			}
+
+		default:
+			text("<")
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// indexASCIIFold returns the index of the first instance of the lowercase
+// ASCII string substr in s, ignoring ASCII case, or -1. Unlike searching a
+// strings.ToLower copy, offsets stay valid in s when folding would change
+// the length of non-ASCII characters.
+func indexASCIIFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		match := true
+		for j := 0; j < len(substr); j++ {
+			c := s[i+j]
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			if c != substr[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseHTMLTag parses the start tag at src[i] ('<'), returning the token and
+// the offset just past it
+func parseHTMLTag(src string, i int) (htmlToken, int) {
+	j := i + 1
+	for j < len(src) && !isHTMLSpace(src[j]) && src[j] != '>' && src[j] != '/' {
+		j++
+	}
+	tok := htmlToken{kind: htmlStartTag, name: strings.ToLower(src[i+1 : j]), attrs: make(map[string]string)}
+
+	for j < len(src) {
+		for j < len(src) && (isHTMLSpace(src[j]) || src[j] == '/') {
+			j++
+		}
+		if j >= len(src) {
+			break
+		}
+		if src[j] == '>' {
+			return tok, j + 1
+		}
+
+		k := j
+		for k < len(src) && !isHTMLSpace(src[k]) && src[k] != '=' && src[k] != '>' && src[k] != '/' {
+			k++
+		}
+		name := strings.ToLower(src[j:k])
+		j = k
+
+		for j < len(src) && isHTMLSpace(src[j]) {
+			j++
+		}
+		value := ""
+		if j < len(src) && src[j] == '=' {
+			j++
+			for j < len(src) && isHTMLSpace(src[j]) {
+				j++
+			}
+			if j < len(src) && (src[j] == '"' || src[j] == '\'') {
+				quote := src[j]
+				end := strings.IndexByte(src[j+1:], quote)
+				if end < 0 {
+					end = len(src) - j - 1
+				}
+				value = src[j+1 : j+1+end]
+				j += end + 2
+			} else {
+				k = j
+				for k < len(src) && !isHTMLSpace(src[k]) && src[k] != '>' {
+					k++
+				}
+				value = src[j:k]
+				j = k
+			}
+		}
+
+		if name != "" {
+			if _, seen := tok.attrs[name]; !seen {
+				tok.attrs[name] = html.UnescapeString(value)
+			}
+		}
+	}
+
+	return tok, len(src)
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,39 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+// Characters whose lowercase form has a different UTF-8 length must not
+// shift the offsets used to find the end of raw text elements
+func TestHTMLToMarkdownCaseFoldLength(t *testing.T) {
+	for _, text := range []string{
+		strings.Repeat("Ⱥ", 50), // lowercases to a longer encoding
+		strings.Repeat("İ", 50),
+		strings.Repeat("K", 50), // Kelvin sign lowercases to ASCII k
+	} {
+		src := "<p>" + text + "</p><SCRIPT>var x = 1</Script><p>after</p>"
+
+		md, _ := htmlToMarkdown(src)
+		if !strings.Contains(md, text) || !strings.Contains(md, "after") {
+			t.Errorf("htmlToMarkdown(%q) = %q", src, md)
+		}
+		if strings.Contains(md, "var x") {
+			t.Errorf("htmlToMarkdown(%q) kept the script: %q", src, md)
+		}
+	}
+}
+
+func TestHTMLToMarkdownEmptyEndTag(t *testing.T) {
+	for _, src := range []string{
+		"<p>before</>after</p>",
+		"<p>before</ >after</p>",
+		"<div>before</\t>after</div>",
+	} {
+		md, _ := htmlToMarkdown(src)
+		if !strings.Contains(md, "before") || !strings.Contains(md, "after") {
+			t.Errorf("htmlToMarkdown(%q) = %q", src, md)
+		}
+	}
+}
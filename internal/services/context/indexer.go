@@ -5,28 +5,75 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/shashwatssp/deeprecall/internal/config"
 	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/services/stt"
 	"github.com/shashwatssp/deeprecall/internal/utils"
 )
 
 type Indexer struct {
 	parser   *Parser
+	ocr      *OCR         // nil unless context.ocr.enabled
+	audio    *Transcriber // nil until EnableTranscription
 	embedder *Embedder
 	cfg      *config.Config
 	cacheMu  sync.RWMutex
 	docCache map[string]*models.Document // filePath -> document
+	partial  map[string]partialRun       // filePath -> interrupted embedding run
+}
+
+// partialRun holds the chunks of an embedding run that failed part-way
+type partialRun struct {
+	docID  string // content hash of the file when the run failed
+	chunks []*models.Chunk
 }
 
 func NewIndexer(cfg *config.Config) *Indexer {
-	return &Indexer{
+	idx := &Indexer{
 		parser:   NewParser(),
 		embedder: NewEmbedder(cfg),
 		cfg:      cfg,
 		docCache: make(map[string]*models.Document),
+		partial:  make(map[string]partialRun),
+	}
+
+	if cfg.Context.OCR.Enabled {
+		idx.ocr = NewOCR(cfg)
+		idx.parser.EnableOCR(idx.ocr)
+	}
+
+	return idx
+}
+
+// Parser returns the document parser, so new formats can be registered
+func (idx *Indexer) Parser() *Parser {
+	return idx.parser
+}
+
+// EnableTranscription indexes .wav, .mp3 and .m4a recordings by
+// transcribing them with service
+func (idx *Indexer) EnableTranscription(service stt.Service) {
+	idx.audio = NewTranscriber(idx.cfg, service)
+	idx.parser.EnableTranscription(idx.audio)
+}
+
+// CheckFormats verifies that every supported extension has a parser and
+// that the external programs OCR and audio decoding need are installed
+func (idx *Indexer) CheckFormats() error {
+	if idx.ocr != nil {
+		if err := idx.ocr.Check(); err != nil {
+			return err
+		}
+	}
+	if idx.audio != nil {
+		if err := idx.audio.Check(idx.cfg.Context.SupportedExtensions); err != nil {
+			return err
+		}
 	}
+	return idx.parser.CheckExtensions(idx.cfg.Context.SupportedExtensions)
 }
 
 // IndexFile processes a single file
@@ -49,6 +96,10 @@ func (idx *Indexer) IndexFile(filePath string, forceReindex bool) ([]*models.Chu
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse document: %w", err)
 	}
+	doc.Metadata["index_version"] = IndexInfoFromConfig(idx.cfg).Fingerprint()
+	for _, warning := range doc.Warnings {
+		logger.Warnf("%s: %s", filePath, warning)
+	}
 
 	// Chunk document
 	chunks := idx.embedder.ChunkDocument(doc)
@@ -58,8 +109,13 @@ func (idx *Indexer) IndexFile(filePath string, forceReindex bool) ([]*models.Chu
 
 	logger.Infof("Created %d chunks from %s", len(chunks), filePath)
 
-	// Generate embeddings
+	// Generate embeddings, resuming an earlier interrupted run if possible
+	idx.resumePartial(filePath, doc.ID, chunks)
 	if err := idx.embedder.CreateEmbeddings(chunks); err != nil {
+		// Keyed by path, so a run for an older version of the file is replaced
+		idx.cacheMu.Lock()
+		idx.partial[filePath] = partialRun{docID: doc.ID, chunks: chunks}
+		idx.cacheMu.Unlock()
 		return nil, fmt.Errorf("failed to create embeddings: %w", err)
 	}
 
@@ -70,11 +126,37 @@ func (idx *Indexer) IndexFile(filePath string, forceReindex bool) ([]*models.Chu
 
 	idx.cacheMu.Lock()
 	idx.docCache[filePath] = doc
+	delete(idx.partial, filePath)
 	idx.cacheMu.Unlock()
 
 	return chunks, nil
 }
 
+// resumePartial copies embeddings from an interrupted run of the same document
+// so only the chunks that were never embedded are sent to the provider again
+func (idx *Indexer) resumePartial(filePath, docID string, chunks []*models.Chunk) {
+	idx.cacheMu.RLock()
+	run, exists := idx.partial[filePath]
+	idx.cacheMu.RUnlock()
+
+	previous := run.chunks
+	if !exists || run.docID != docID || len(previous) != len(chunks) {
+		return
+	}
+
+	resumed := 0
+	for i, chunk := range chunks {
+		if len(previous[i].Embedding) > 0 && previous[i].Content == chunk.Content {
+			chunk.Embedding = previous[i].Embedding
+			resumed++
+		}
+	}
+
+	if resumed > 0 {
+		utils.GetLogger().Infof("Resuming embeddings for %s: %d/%d chunks already embedded", docID, resumed, len(chunks))
+	}
+}
+
 // needsReindex checks if a file needs to be reindexed
 func (idx *Indexer) needsReindex(filePath string) (*models.Document, bool) {
 	idx.cacheMu.RLock()
@@ -106,6 +188,11 @@ func (idx *Indexer) needsReindex(filePath string) (*models.Document, bool) {
 		return cached, true
 	}
 
+	// Chunks built with a different model or chunking must be rebuilt
+	if cached.Metadata["index_version"] != IndexInfoFromConfig(idx.cfg).Fingerprint() {
+		return cached, true
+	}
+
 	// Check modification time as backup
 	if info.ModTime().After(cached.FileModTime) {
 		return cached, true
@@ -195,6 +282,11 @@ func (idx *Indexer) loadCachedChunks(docID string) ([]*models.Chunk, error) {
 	return chunks, nil
 }
 
+// Close releases resources held by the indexer
+func (idx *Indexer) Close() error {
+	return idx.embedder.Close()
+}
+
 // IndexDirectory indexes all supported files in a directory
 func (idx *Indexer) IndexDirectory(dirPath string) (map[string][]*models.Chunk, error) {
 	logger := utils.GetLogger()
@@ -206,19 +298,14 @@ func (idx *Indexer) IndexDirectory(dirPath string) (map[string][]*models.Chunk,
 		}
 
 		if info.IsDir() {
-			return nil
-		}
-
-		ext := filepath.Ext(path)
-		supported := false
-		for _, supportedExt := range idx.cfg.Context.SupportedExtensions {
-			if ext == supportedExt {
-				supported = true
-				break
+			// Hidden directories (.git, .cache) hold tool state, not context
+			if path != dirPath && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
 			}
+			return nil
 		}
 
-		if !supported {
+		if !idx.indexable(path) {
 			return nil
 		}
 
@@ -234,3 +321,21 @@ func (idx *Indexer) IndexDirectory(dirPath string) (map[string][]*models.Chunk,
 
 	return results, err
 }
+
+// indexable reports whether a file should be indexed: its extension, in any
+// case, is one of the supported ones, or it has no extension with a parser
+// and its content sniffs as a format that has one
+func (idx *Indexer) indexable(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, supported := range idx.cfg.Context.SupportedExtensions {
+		if ext == strings.ToLower(supported) {
+			return true
+		}
+	}
+
+	// Formats known by extension but left out of the config stay out
+	if idx.parser.HasExtension(ext) || strings.HasPrefix(filepath.Base(path), ".") {
+		return false
+	}
+	return idx.parser.Sniffable(path)
+}
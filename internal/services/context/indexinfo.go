@@ -0,0 +1,54 @@
+package context
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// IndexInfo describes the embedding space and chunking parameters an index
+// was built with. Vectors from indexes with different info are not comparable.
+type IndexInfo struct {
+	Model        string
+	Dimension    int
+	ChunkMethod  string
+	ChunkSize    int
+	ChunkOverlap int
+	MinChunkSize int
+	Tokenizer    string
+}
+
+// IndexInfoFromConfig returns the index parameters for the current configuration
+func IndexInfoFromConfig(cfg *config.Config) IndexInfo {
+	return IndexInfo{
+		Model:        cfg.Context.Embeddings.Model,
+		Dimension:    cfg.Context.Embeddings.Dimension,
+		ChunkMethod:  cfg.Context.Chunking.Method,
+		ChunkSize:    cfg.Context.Chunking.ChunkSize,
+		ChunkOverlap: cfg.Context.Chunking.ChunkOverlap,
+		MinChunkSize: cfg.Context.Chunking.MinChunkSize,
+		Tokenizer:    tokenizerName(cfg),
+	}
+}
+
+// tokenizerName names the tokenizer chunking actually uses. An index built
+// with the estimate differs from one built with the vocab it fell back from.
+func tokenizerName(cfg *config.Config) string {
+	if _, ok := NewTokenizer(cfg).(*approxTokenizer); ok {
+		return "approx"
+	}
+	return filepath.Base(cfg.Context.Chunking.TokenizerVocab)
+}
+
+// Fingerprint returns a stable identifier for these parameters
+func (i IndexInfo) Fingerprint() string {
+	return utils.StringHash(fmt.Sprintf("%s|%d|%s|%d|%d|%d|%s",
+		i.Model, i.Dimension, i.ChunkMethod, i.ChunkSize, i.ChunkOverlap, i.MinChunkSize, i.Tokenizer))
+}
+
+func (i IndexInfo) String() string {
+	return fmt.Sprintf("model=%s dim=%d chunking=%s/%d/%d/%d tokenizer=%s",
+		i.Model, i.Dimension, i.ChunkMethod, i.ChunkSize, i.ChunkOverlap, i.MinChunkSize, i.Tokenizer)
+}
@@ -0,0 +1,317 @@
+package context
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+var (
+	atxHeadingRe     = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextUnderRe    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	tableSeparatorRe = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// mdSection is the content under one heading
+type mdSection struct {
+	path    []string // heading titles from the top level down
+	heading string   // original heading line, empty for the preamble
+	blocks  []mdBlock
+}
+
+// mdBlock is a paragraph, list, code block or table
+type mdBlock struct {
+	text   string
+	atomic bool // code blocks and tables are never split
+}
+
+// isMarkdown reports whether a document should use the Markdown chunker.
+// Structured formats (DOCX, ODT, RTF) are converted to Markdown by their parsers.
+func isMarkdown(doc *models.Document) bool {
+	ext := doc.Metadata["extension"]
+	return ext == ".md" || ext == ".markdown" || doc.Metadata["mime_type"] == "text/markdown" ||
+		doc.Metadata["content_format"] == "markdown"
+}
+
+// chunkMarkdown splits a Markdown document on heading boundaries. Each chunk
+// starts with its heading path ("Project X > Design > Storage"), which is also
+// stored in the chunk metadata. Code blocks and tables are kept intact.
+func (e *Embedder) chunkMarkdown(doc *models.Document, text string, size, overlap, minSize int) []*models.Chunk {
+	sections := parseMarkdown(text)
+
+	// Sections too small to stand alone are merged with the sections nested under them
+	var groups [][]mdSection
+	var groupTokens []int
+	for i := 0; i < len(sections); {
+		group := []mdSection{sections[i]}
+		tokens := e.sectionTokens(sections[i])
+		i++
+		for i < len(sections) && tokens < minSize && hasPathPrefix(sections[i].path, group[0].path) {
+			next := e.sectionTokens(sections[i])
+			if tokens+next > size {
+				break
+			}
+			group = append(group, sections[i])
+			tokens += next
+			i++
+		}
+		groups = append(groups, group)
+		groupTokens = append(groupTokens, tokens)
+	}
+
+	// A leaf still too small would be dropped by the minimum size filter, so
+	// it joins a sibling: the previous group when that fits, else the next
+	for i := 0; i < len(groups) && len(groups) > 1; {
+		if groupTokens[i] >= minSize {
+			i++
+			continue
+		}
+
+		target := i - 1
+		if i == 0 || (groupTokens[i-1]+groupTokens[i] > size && i+1 < len(groups) && groupTokens[i+1]+groupTokens[i] <= size) {
+			target = i + 1
+		}
+
+		first, second := min(i, target), max(i, target)
+		groups[first] = append(groups[first], groups[second]...)
+		groupTokens[first] += groupTokens[second]
+		groups = append(groups[:second], groups[second+1:]...)
+		groupTokens = append(groupTokens[:second], groupTokens[second+1:]...)
+		i = first
+	}
+
+	var chunks []*models.Chunk
+	for _, group := range groups {
+		chunks = append(chunks, e.chunkSections(doc, group, size, overlap, len(chunks))...)
+	}
+
+	return chunks
+}
+
+// chunkSections packs the blocks of a group of sections into chunks that
+// share the heading path common to the group
+func (e *Embedder) chunkSections(doc *models.Document, group []mdSection, size, overlap, firstIndex int) []*models.Chunk {
+	path := group[0].path
+	for _, section := range group[1:] {
+		path = commonPath(path, section.path)
+	}
+	breadcrumb := strings.Join(path, " > ")
+
+	budget := size - e.tokenizer.Count(breadcrumb) - 1
+	if budget < size/2 {
+		budget = size / 2
+	}
+
+	var blocks []mdBlock
+	for _, section := range group {
+		// Headings below the breadcrumb stay in the text
+		if len(section.path) > len(path) && section.heading != "" {
+			blocks = append(blocks, mdBlock{text: section.heading})
+		}
+		blocks = append(blocks, section.blocks...)
+	}
+
+	var chunks []*models.Chunk
+	var current strings.Builder
+	currentTokens := 0
+
+	emit := func(body string) {
+		content := body
+		if breadcrumb != "" {
+			content = breadcrumb + "\n\n" + body
+		}
+
+		chunk := newChunk(doc, content, firstIndex+len(chunks))
+		if breadcrumb != "" {
+			chunk.Metadata["heading_path"] = breadcrumb
+			chunk.Metadata["heading"] = path[len(path)-1]
+			chunk.Metadata["heading_level"] = len(path)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			emit(current.String())
+		}
+		current.Reset()
+		currentTokens = 0
+	}
+
+	for _, block := range blocks {
+		blockTokens := e.tokenizer.Count(block.text)
+
+		if currentTokens+blockTokens > budget && current.Len() > 0 {
+			flush()
+		}
+
+		if blockTokens > budget {
+			if block.atomic {
+				emit(block.text)
+			} else {
+				for _, window := range e.splitByTokens(block.text, budget, overlap) {
+					emit(window)
+				}
+			}
+			continue
+		}
+
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(block.text)
+		currentTokens += blockTokens
+	}
+	flush()
+
+	return chunks
+}
+
+func (e *Embedder) sectionTokens(section mdSection) int {
+	tokens := e.tokenizer.Count(section.heading)
+	for _, block := range section.blocks {
+		tokens += e.tokenizer.Count(block.text)
+	}
+	return tokens
+}
+
+// parseMarkdown splits Markdown into sections by ATX (#) and setext
+// (underlined) headings and each section into blocks
+func parseMarkdown(text string) []mdSection {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	type heading struct {
+		level int
+		title string
+	}
+	var stack []heading
+
+	sections := []mdSection{{}}
+	current := &sections[0]
+	var paragraph []string
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			current.blocks = append(current.blocks, mdBlock{text: strings.Join(paragraph, "\n")})
+			paragraph = nil
+		}
+	}
+
+	startSection := func(level int, title, line string) {
+		for len(stack) > 0 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, heading{level: level, title: title})
+
+		path := make([]string, len(stack))
+		for i, h := range stack {
+			path[i] = h.title
+		}
+
+		sections = append(sections, mdSection{path: path, heading: line})
+		current = &sections[len(sections)-1]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		// Fenced code block: everything up to the closing fence is one block
+		if fence := codeFence(line); fence != "" {
+			flushParagraph()
+			block := []string{line}
+			for i++; i < len(lines); i++ {
+				block = append(block, lines[i])
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) && strings.Trim(strings.TrimSpace(lines[i]), fence[:1]) == "" {
+					break
+				}
+			}
+			current.blocks = append(current.blocks, mdBlock{text: strings.Join(block, "\n"), atomic: true})
+			continue
+		}
+
+		if m := atxHeadingRe.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			startSection(len(m[1]), strings.TrimSpace(m[2]), trimmed)
+			continue
+		}
+
+		// Setext heading: underline directly below paragraph text
+		if m := setextUnderRe.FindStringSubmatch(line); m != nil && len(paragraph) > 0 {
+			title := strings.Join(strings.Fields(strings.Join(paragraph, " ")), " ")
+			paragraph = nil
+			level := 2
+			if m[1][0] == '=' {
+				level = 1
+			}
+			startSection(level, title, title)
+			continue
+		}
+
+		// Table: header row followed by a separator row, until the first non-table line
+		if strings.Contains(line, "|") && i+1 < len(lines) && tableSeparatorRe.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-") {
+			flushParagraph()
+			block := []string{line}
+			for i++; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+				block = append(block, lines[i])
+			}
+			i--
+			current.blocks = append(current.blocks, mdBlock{text: strings.Join(block, "\n"), atomic: true})
+			continue
+		}
+
+		if trimmed == "" {
+			flushParagraph()
+			continue
+		}
+
+		paragraph = append(paragraph, line)
+	}
+	flushParagraph()
+
+	// Drop an empty preamble
+	if len(sections[0].blocks) == 0 {
+		sections = sections[1:]
+	}
+
+	return sections
+}
+
+// codeFence returns the fence marker if line opens a fenced code block
+func codeFence(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return ""
+	}
+
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, marker) {
+			n := len(trimmed) - len(strings.TrimLeft(trimmed, marker[:1]))
+			return strings.Repeat(marker[:1], n)
+		}
+	}
+	return ""
+}
+
+// hasPathPrefix reports whether path is nested under (or equal to) prefix
+func hasPathPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// commonPath returns the longest heading path both a and b are nested under
+func commonPath(a, b []string) []string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
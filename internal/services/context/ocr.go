@@ -0,0 +1,267 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// OCREngine recognizes the text in an image file
+type OCREngine interface {
+	Recognize(ctx context.Context, imagePath string) (*OCRResult, error)
+}
+
+// OCRResult is recognized text with its mean word confidence in [0, 1]
+type OCRResult struct {
+	Text       string
+	Confidence float64
+}
+
+// PageRenderer rasterizes PDF pages so they can be passed to an OCREngine
+type PageRenderer interface {
+	// RenderPage writes the 1-based page to an image file. cleanup removes it.
+	RenderPage(ctx context.Context, pdfPath string, page int) (imagePath string, cleanup func(), err error)
+}
+
+// OCR recognizes text in images and in PDF pages that have no text layer
+type OCR struct {
+	engine   OCREngine
+	renderer PageRenderer
+	timeout  time.Duration
+	commands []string // binaries that must be installed
+}
+
+// NewOCR creates an OCR backed by the tesseract and pdftoppm binaries
+func NewOCR(cfg *config.Config) *OCR {
+	ocrCfg := cfg.Context.OCR
+
+	command := ocrCfg.Command
+	if command == "" {
+		command = "tesseract"
+	}
+	languages := ocrCfg.Languages
+	if languages == "" {
+		languages = "eng"
+	}
+	renderer := ocrCfg.PDFRenderer
+	if renderer == "" {
+		renderer = "pdftoppm"
+	}
+	dpi := ocrCfg.DPI
+	if dpi <= 0 {
+		dpi = 300
+	}
+	timeout := time.Duration(ocrCfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	ocr := NewOCRWithEngine(
+		&tesseractEngine{command: command, languages: languages, run: utils.RunCommand},
+		&pdftoppmRenderer{command: renderer, dpi: dpi, run: utils.RunCommand},
+		timeout,
+	)
+	ocr.commands = []string{command, renderer}
+	return ocr
+}
+
+// NewOCRWithEngine creates an OCR from its parts, so tests can substitute
+// fakes for the external programs. renderer may be nil to leave scanned PDF
+// pages unrecognized.
+func NewOCRWithEngine(engine OCREngine, renderer PageRenderer, timeout time.Duration) *OCR {
+	return &OCR{engine: engine, renderer: renderer, timeout: timeout}
+}
+
+// Check verifies that the external programs are installed
+func (o *OCR) Check() error {
+	for _, command := range o.commands {
+		if _, err := exec.LookPath(command); err != nil {
+			return fmt.Errorf("OCR is enabled but %s was not found: %w", command, err)
+		}
+	}
+	return nil
+}
+
+// recognize reads the text of an image
+func (o *OCR) recognize(imagePath string) (*OCRResult, error) {
+	ctx, cancel := o.context()
+	defer cancel()
+
+	return o.engine.Recognize(ctx, imagePath)
+}
+
+// recognizePage renders a PDF page and reads its text
+func (o *OCR) recognizePage(pdfPath string, page int) (*OCRResult, error) {
+	if o.renderer == nil {
+		return nil, fmt.Errorf("no PDF page renderer configured")
+	}
+
+	ctx, cancel := o.context()
+	defer cancel()
+
+	imagePath, cleanup, err := o.renderer.RenderPage(ctx, pdfPath, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render page: %w", err)
+	}
+	defer cleanup()
+
+	return o.engine.Recognize(ctx, imagePath)
+}
+
+func (o *OCR) context() (context.Context, context.CancelFunc) {
+	if o.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), o.timeout)
+}
+
+// parseImage reads the text of a PNG or JPEG image
+func (o *OCR) parseImage(filePath string, doc *models.Document) error {
+	result, err := o.recognize(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to recognize image text: %w", err)
+	}
+
+	doc.Content = result.Text
+	doc.OCRConfidence = map[int]float64{1: result.Confidence}
+	doc.Metadata["ocr_confidence"] = strconv.FormatFloat(result.Confidence, 'f', 2, 64)
+
+	return nil
+}
+
+// parsePDF extracts a PDF, recognizing pages without a text layer
+func (o *OCR) parsePDF(filePath string, doc *models.Document) error {
+	return extractPDF(filePath, doc, o)
+}
+
+// annotateOCR records in chunk metadata how confident OCR was in the text a
+// chunk came from. Chunks spanning several pages get the lowest confidence
+// among them; chunks entirely on text-layer pages get none.
+func annotateOCR(doc *models.Document, chunks []*models.Chunk) {
+	for _, chunk := range chunks {
+		first, last := 1, 1
+		if len(doc.PageOffsets) > 0 {
+			var ok bool
+			if first, ok = chunk.Metadata["page"].(int); !ok {
+				continue
+			}
+			if last, ok = chunk.Metadata["page_end"].(int); !ok {
+				last = first
+			}
+		}
+
+		confidence, found := 1.0, false
+		for page := first; page <= last; page++ {
+			if c, ok := doc.OCRConfidence[page]; ok {
+				confidence, found = min(confidence, c), true
+			}
+		}
+		if found {
+			chunk.Metadata["ocr_confidence"] = confidence
+		}
+	}
+}
+
+// tesseractEngine runs the tesseract command line tool
+type tesseractEngine struct {
+	command   string
+	languages string
+	run       utils.CommandRunner
+}
+
+func (t *tesseractEngine) Recognize(ctx context.Context, imagePath string) (*OCRResult, error) {
+	out, err := t.run(ctx, t.command, imagePath, "stdout", "-l", t.languages, "tsv")
+	if err != nil {
+		return nil, fmt.Errorf("tesseract failed: %w", err)
+	}
+	return parseTesseractTSV(string(out)), nil
+}
+
+// parseTesseractTSV rebuilds lines and paragraphs from tesseract's word
+// table, averaging the word confidences
+func parseTesseractTSV(tsv string) *OCRResult {
+	// level page_num block_num par_num line_num word_num left top width height conf text
+	const (
+		colLevel = 0
+		colBlock = 2
+		colPar   = 3
+		colLine  = 4
+		colConf  = 10
+		colText  = 11
+	)
+
+	var text strings.Builder
+	var total float64
+	words := 0
+	var lastPar, lastLine string
+
+	for _, row := range strings.Split(tsv, "\n") {
+		fields := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if len(fields) <= colText || fields[colLevel] != "5" {
+			continue
+		}
+
+		word := strings.TrimSpace(fields[colText])
+		conf, err := strconv.ParseFloat(fields[colConf], 64)
+		if word == "" || err != nil || conf < 0 {
+			continue
+		}
+
+		par := fields[colBlock] + "/" + fields[colPar]
+		line := par + "/" + fields[colLine]
+		switch {
+		case words == 0:
+		case par != lastPar:
+			text.WriteString("\n\n")
+		case line != lastLine:
+			text.WriteString("\n")
+		default:
+			text.WriteString(" ")
+		}
+		text.WriteString(word)
+		lastPar, lastLine = par, line
+
+		total += conf
+		words++
+	}
+
+	result := &OCRResult{Text: text.String()}
+	if words > 0 {
+		result.Confidence = total / float64(words) / 100
+	}
+	return result
+}
+
+// pdftoppmRenderer rasterizes pages with poppler's pdftoppm
+type pdftoppmRenderer struct {
+	command string
+	dpi     int
+	run     utils.CommandRunner
+}
+
+func (r *pdftoppmRenderer) RenderPage(ctx context.Context, pdfPath string, page int) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "deeprecall-ocr-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	prefix := filepath.Join(dir, "page")
+	n := strconv.Itoa(page)
+	args := []string{"-r", strconv.Itoa(r.dpi), "-f", n, "-l", n, "-gray", "-png", "-singlefile", pdfPath, prefix}
+	if _, err := r.run(ctx, r.command, args...); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("pdftoppm failed: %w", err)
+	}
+
+	return prefix + ".png", cleanup, nil
+}
@@ -0,0 +1,210 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// fakeRenderer "renders" a page by naming an image after it
+type fakeRenderer struct{ rendered []int }
+
+func (r *fakeRenderer) RenderPage(ctx context.Context, pdfPath string, page int) (string, func(), error) {
+	r.rendered = append(r.rendered, page)
+	return fmt.Sprintf("page-%d.png", page), func() {}, nil
+}
+
+// fakeEngine returns a canned result per image path
+type fakeEngine map[string]*OCRResult
+
+func (e fakeEngine) Recognize(ctx context.Context, imagePath string) (*OCRResult, error) {
+	result, ok := e[imagePath]
+	if !ok {
+		return nil, fmt.Errorf("unexpected image %s", imagePath)
+	}
+	return result, nil
+}
+
+// writePDF writes a PDF with one page per entry; empty entries get no content
+// stream, like a scanned page without a text layer
+func writePDF(t *testing.T, pages []string) string {
+	t.Helper()
+
+	n := len(pages)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // page tree, filled in below
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var kids []string
+	for _, text := range pages {
+		pageNum := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageNum))
+		if text == "" {
+			objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>")
+			continue
+		}
+		stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageNum+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n)
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(t.TempDir(), "scan.pdf")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseTesseractTSV(t *testing.T) {
+	tsv := strings.Join([]string{
+		"level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext",
+		"1\t1\t0\t0\t0\t0\t0\t0\t100\t100\t-1\t",
+		"5\t1\t1\t1\t1\t1\t0\t0\t10\t10\t90\tHello",
+		"5\t1\t1\t1\t1\t2\t0\t0\t10\t10\t80\tworld",
+		"5\t1\t1\t1\t1\t3\t0\t0\t10\t10\t-1\tnoise",
+		"5\t1\t1\t1\t2\t1\t0\t0\t10\t10\t70\tsecond",
+		"5\t1\t1\t2\t1\t1\t0\t0\t10\t10\t60\tnext\r",
+		"5\t1\t1\t2\t1\t2\t0\t0\t10\t10\t95\t ",
+	}, "\n")
+
+	result := parseTesseractTSV(tsv)
+
+	if want := "Hello world\nsecond\n\nnext"; result.Text != want {
+		t.Errorf("text = %q, want %q", result.Text, want)
+	}
+	if want := 0.75; math.Abs(result.Confidence-want) > 1e-9 {
+		t.Errorf("confidence = %v, want %v", result.Confidence, want)
+	}
+
+	if empty := parseTesseractTSV(""); empty.Text != "" || empty.Confidence != 0 {
+		t.Errorf("empty input = %+v", empty)
+	}
+}
+
+func TestTesseractEngineRunner(t *testing.T) {
+	var gotName string
+	var gotArgs []string
+	engine := &tesseractEngine{
+		command:   "tesseract",
+		languages: "eng+deu",
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			gotName, gotArgs = name, args
+			return []byte("5\t1\t1\t1\t1\t1\t0\t0\t1\t1\t50\tword\n"), nil
+		},
+	}
+
+	result, err := engine.Recognize(context.Background(), "img.png")
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if result.Text != "word" || result.Confidence != 0.5 {
+		t.Errorf("result = %+v", result)
+	}
+	wantArgs := []string{"img.png", "stdout", "-l", "eng+deu", "tsv"}
+	if gotName != "tesseract" || !reflect.DeepEqual(gotArgs, wantArgs) {
+		t.Errorf("ran %s %v, want tesseract %v", gotName, gotArgs, wantArgs)
+	}
+}
+
+func TestAnnotateOCR(t *testing.T) {
+	doc := &models.Document{
+		PageOffsets:   []int{0, 100, 200},
+		OCRConfidence: map[int]float64{2: 0.8, 3: 0.6},
+	}
+	chunks := []*models.Chunk{
+		{Metadata: map[string]interface{}{"page": 1, "page_end": 1}},
+		{Metadata: map[string]interface{}{"page": 2, "page_end": 2}},
+		{Metadata: map[string]interface{}{"page": 1, "page_end": 3}},
+		{Metadata: map[string]interface{}{"page": 3}},
+		{Metadata: map[string]interface{}{}},
+	}
+
+	annotateOCR(doc, chunks)
+
+	want := []interface{}{nil, 0.8, 0.6, 0.6, nil}
+	for i, chunk := range chunks {
+		if got := chunk.Metadata["ocr_confidence"]; got != want[i] {
+			t.Errorf("chunk %d: ocr_confidence = %v, want %v", i, got, want[i])
+		}
+	}
+
+	// Images have no page offsets; everything counts as page 1
+	image := &models.Document{OCRConfidence: map[int]float64{1: 0.9}}
+	chunk := &models.Chunk{Metadata: map[string]interface{}{}}
+	annotateOCR(image, []*models.Chunk{chunk})
+	if got := chunk.Metadata["ocr_confidence"]; got != 0.9 {
+		t.Errorf("image chunk: ocr_confidence = %v, want 0.9", got)
+	}
+}
+
+func TestExtractPDFScannedPage(t *testing.T) {
+	path := writePDF(t, []string{"Typed first page.", "", "Typed third page."})
+
+	renderer := &fakeRenderer{}
+	engine := fakeEngine{"page-2.png": {Text: "Scanned second page.", Confidence: 0.7}}
+	ocr := NewOCRWithEngine(engine, renderer, 0)
+
+	doc := &models.Document{Metadata: make(map[string]string)}
+	if err := ocr.parsePDF(path, doc); err != nil {
+		t.Fatalf("parsePDF: %v", err)
+	}
+
+	if !reflect.DeepEqual(renderer.rendered, []int{2}) {
+		t.Errorf("rendered pages %v, want [2]", renderer.rendered)
+	}
+	if !reflect.DeepEqual(doc.OCRConfidence, map[int]float64{2: 0.7}) {
+		t.Errorf("OCRConfidence = %v", doc.OCRConfidence)
+	}
+	if doc.Metadata["ocr_pages"] != "2" {
+		t.Errorf("ocr_pages = %q, want \"2\"", doc.Metadata["ocr_pages"])
+	}
+	if len(doc.Warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", doc.Warnings)
+	}
+
+	if len(doc.PageOffsets) != 3 {
+		t.Fatalf("PageOffsets = %v, want 3 pages", doc.PageOffsets)
+	}
+	for i, want := range []string{"Typed first page.", "Scanned second page.", "Typed third page."} {
+		if !strings.HasPrefix(doc.Content[doc.PageOffsets[i]:], want) {
+			t.Errorf("page %d starts with %q, want %q", i+1, doc.Content[doc.PageOffsets[i]:], want)
+		}
+	}
+
+	// Without OCR the scanned page is reported instead
+	plain := &models.Document{Metadata: make(map[string]string)}
+	if err := parsePDF(path, plain); err != nil {
+		t.Fatalf("parsePDF: %v", err)
+	}
+	if plain.OCRConfidence != nil || strings.Contains(plain.Content, "Scanned") {
+		t.Errorf("plain parse used OCR: %+v", plain)
+	}
+	if !reflect.DeepEqual(plain.Warnings, []string{"page 2: no extractable text"}) {
+		t.Errorf("warnings = %v", plain.Warnings)
+	}
+}
@@ -0,0 +1,416 @@
+package context
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+var headingStyleRe = regexp.MustCompile(`(?i)^heading\s*(\d)$`)
+
+// parseDOCX extracts text from an Office Open XML (.docx) document,
+// rendering headings, lists and tables as Markdown
+func parseDOCX(filePath string, doc *models.Document) error {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open DOCX: %w", err)
+	}
+	defer zr.Close()
+
+	body, err := readZipEntry(&zr.Reader, "word/document.xml")
+	if err != nil {
+		return fmt.Errorf("failed to read DOCX body: %w", err)
+	}
+
+	// Style definitions are optional; without them only built-in style IDs are recognized
+	levels := map[string]int{}
+	if styles, err := readZipEntry(&zr.Reader, "word/styles.xml"); err == nil {
+		levels = docxHeadingStyles(styles)
+	}
+
+	content, err := docxText(body, levels)
+	if err != nil {
+		return fmt.Errorf("failed to parse DOCX: %w", err)
+	}
+
+	doc.Content = content
+	doc.Metadata["content_format"] = "markdown"
+
+	if core, err := readZipEntry(&zr.Reader, "docProps/core.xml"); err == nil {
+		setMetadata(doc.Metadata, coreProperties(core))
+	}
+
+	return nil
+}
+
+// docxHeadingStyles maps paragraph style IDs to heading levels using the
+// style name ("heading 2") or its outline level
+func docxHeadingStyles(data []byte) map[string]int {
+	levels := make(map[string]int)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var styleID string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return levels
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "style":
+			styleID = xmlAttr(start, "styleId")
+		case "name":
+			name := xmlAttr(start, "val")
+			if m := headingStyleRe.FindStringSubmatch(name); m != nil {
+				levels[styleID], _ = strconv.Atoi(m[1])
+			} else if strings.EqualFold(name, "title") {
+				levels[styleID] = 1
+			}
+		case "outlineLvl":
+			if _, named := levels[styleID]; !named && styleID != "" {
+				if lvl, err := strconv.Atoi(xmlAttr(start, "val")); err == nil && lvl < 9 {
+					levels[styleID] = lvl + 1
+				}
+			}
+		}
+	}
+}
+
+// docxText walks word/document.xml paragraph by paragraph
+func docxText(data []byte, styleLevels map[string]int) (string, error) {
+	var out markdownWriter
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var (
+		para       strings.Builder
+		level      int  // heading level of the current paragraph
+		listDepth  = -1 // list nesting of the current paragraph, -1 if not a list item
+		inText     bool
+		inProps    bool // tab stops inside paragraph properties are not text
+		tableDepth int
+		row        []string
+		cell       strings.Builder
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				level, listDepth = 0, -1
+			case "pPr":
+				inProps = true
+			case "pStyle":
+				style := xmlAttr(t, "val")
+				if lvl, ok := styleLevels[style]; ok {
+					level = lvl
+				} else if m := headingStyleRe.FindStringSubmatch(style); m != nil {
+					level, _ = strconv.Atoi(m[1])
+				} else if style == "Title" {
+					level = 1
+				}
+			case "outlineLvl":
+				if lvl, err := strconv.Atoi(xmlAttr(t, "val")); err == nil && lvl < 9 {
+					level = lvl + 1
+				}
+			case "numPr":
+				listDepth = 0
+			case "ilvl":
+				if lvl, err := strconv.Atoi(xmlAttr(t, "val")); err == nil {
+					listDepth = lvl
+				}
+			case "t":
+				inText = true
+			case "tab":
+				if !inProps {
+					para.WriteString("\t")
+				}
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				tableDepth++
+			case "tr":
+				row = nil
+			case "tc":
+				cell.Reset()
+			}
+
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "pPr":
+				inProps = false
+			case "p":
+				text := para.String()
+				switch {
+				case tableDepth > 0:
+					if cell.Len() > 0 {
+						cell.WriteString(" ")
+					}
+					cell.WriteString(text)
+				case level > 0:
+					out.Heading(level, text)
+				case listDepth >= 0:
+					out.ListItem(text, listDepth)
+				default:
+					out.Paragraph(text)
+				}
+			case "tc":
+				row = append(row, cell.String())
+			case "tr":
+				if tableDepth == 1 {
+					out.TableRow(row)
+				}
+			case "tbl":
+				tableDepth--
+				if tableDepth == 0 {
+					out.EndTable()
+				}
+			}
+		}
+	}
+
+	return out.String(), nil
+}
+
+// parseODT extracts text from an OpenDocument text (.odt) document,
+// rendering headings, lists and tables as Markdown
+func parseODT(filePath string, doc *models.Document) error {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open ODT: %w", err)
+	}
+	defer zr.Close()
+
+	body, err := readZipEntry(&zr.Reader, "content.xml")
+	if err != nil {
+		return fmt.Errorf("failed to read ODT content: %w", err)
+	}
+
+	content, err := odtText(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse ODT: %w", err)
+	}
+
+	doc.Content = content
+	doc.Metadata["content_format"] = "markdown"
+
+	if meta, err := readZipEntry(&zr.Reader, "meta.xml"); err == nil {
+		setMetadata(doc.Metadata, coreProperties(meta))
+	}
+
+	return nil
+}
+
+// odtText walks content.xml, collecting text:h and text:p elements
+func odtText(data []byte) (string, error) {
+	var out markdownWriter
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var (
+		para       strings.Builder
+		depth      int // nesting inside the current text:p / text:h
+		level      int
+		listDepth  = -1
+		skip       int // inside notes and annotations
+		tableDepth int
+		row        []string
+		cell       strings.Builder
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "note", "annotation", "tracked-changes":
+				skip = 1
+			case "list":
+				listDepth++
+			case "h", "p":
+				if depth == 0 {
+					para.Reset()
+					level = 0
+					if t.Name.Local == "h" {
+						level = 1
+						if lvl, err := strconv.Atoi(xmlAttr(t, "outline-level")); err == nil {
+							level = lvl
+						}
+					}
+				}
+				depth++
+			case "s":
+				count := 1
+				if c, err := strconv.Atoi(xmlAttr(t, "c")); err == nil {
+					count = c
+				}
+				para.WriteString(strings.Repeat(" ", count))
+			case "tab":
+				para.WriteString("\t")
+			case "line-break":
+				para.WriteString("\n")
+			case "table":
+				tableDepth++
+			case "table-row":
+				row = nil
+			case "table-cell":
+				cell.Reset()
+			}
+
+		case xml.CharData:
+			if skip == 0 && depth > 0 {
+				para.Write(t)
+			}
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "list":
+				listDepth--
+			case "h", "p":
+				depth--
+				if depth > 0 {
+					continue
+				}
+				text := para.String()
+				switch {
+				case tableDepth > 0:
+					if cell.Len() > 0 {
+						cell.WriteString(" ")
+					}
+					cell.WriteString(text)
+				case level > 0:
+					out.Heading(level, text)
+				case listDepth >= 0:
+					out.ListItem(text, listDepth)
+				default:
+					out.Paragraph(text)
+				}
+			case "table-cell":
+				row = append(row, cell.String())
+			case "table-row":
+				if tableDepth == 1 {
+					out.TableRow(row)
+				}
+			case "table":
+				tableDepth--
+				if tableDepth == 0 {
+					out.EndTable()
+				}
+			}
+		}
+	}
+
+	return out.String(), nil
+}
+
+// coreProperties reads Dublin Core document properties, as used by both
+// docProps/core.xml (OOXML) and meta.xml (OpenDocument)
+func coreProperties(data []byte) map[string]string {
+	props := make(map[string]string)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	keys := map[string]string{
+		"title":           "title",
+		"subject":         "subject",
+		"creator":         "author",
+		"initial-creator": "author",
+		"keywords":        "keywords",
+		"keyword":         "keywords",
+		"description":     "description",
+		"lastModifiedBy":  "last_modified_by",
+		"created":         "created",
+		"creation-date":   "created",
+		"modified":        "modified",
+		"date":            "modified",
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		key, ok := keys[start.Name.Local]
+		if !ok {
+			continue
+		}
+
+		var value string
+		if err := dec.DecodeElement(&value, &start); err != nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		switch {
+		case key == "created" || key == "modified":
+			value = normalizeDate(value)
+		case key == "keywords" && props[key] != "":
+			value = props[key] + ", " + value
+		case key == "author" && props[key] != "":
+			// dc:creator (last editor in ODF) shouldn't override the initial creator
+			continue
+		}
+		props[key] = value
+	}
+
+	return props
+}
+
+// xmlAttr returns the value of the attribute with the given local name
+func xmlAttr(el xml.StartElement, local string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
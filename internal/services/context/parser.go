@@ -1,60 +1,154 @@
 package context
 
 import (
-	"bufio"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
-	"github.com/ledongthuc/pdf"
 	"github.com/shashwatssp/deeprecall/internal/models"
 	"github.com/shashwatssp/deeprecall/internal/utils"
 )
 
-type Parser struct{}
+// FormatParser extracts text from one document format
+type FormatParser interface {
+	// Parse reads filePath into doc.Content, adding any format-specific
+	// properties to doc.Metadata
+	Parse(filePath string, doc *models.Document) error
+}
+
+// FormatParserFunc adapts a function to the FormatParser interface
+type FormatParserFunc func(filePath string, doc *models.Document) error
+
+func (f FormatParserFunc) Parse(filePath string, doc *models.Document) error {
+	return f(filePath, doc)
+}
+
+// Parser dispatches documents to format parsers registered by file
+// extension, falling back to the sniffed MIME type
+type Parser struct {
+	mu     sync.RWMutex
+	byExt  map[string]FormatParser
+	byMIME map[string]FormatParser
+}
 
 func NewParser() *Parser {
-	return &Parser{}
+	p := &Parser{
+		byExt:  make(map[string]FormatParser),
+		byMIME: make(map[string]FormatParser),
+	}
+
+	p.Register(FormatParserFunc(parsePDF), []string{".pdf"}, []string{"application/pdf"})
+	p.Register(FormatParserFunc(parseText), []string{".txt", ".md", ".markdown"}, []string{"text/plain", "text/markdown"})
+	p.Register(FormatParserFunc(parseCode), []string{".go", ".py", ".ts", ".tsx", ".js", ".jsx"}, nil)
+	p.Register(FormatParserFunc(parseDOCX), []string{".docx"}, nil)
+	p.Register(FormatParserFunc(parseODT), []string{".odt"}, nil)
+	p.Register(FormatParserFunc(parseRTF), []string{".rtf"}, []string{"text/rtf", "application/rtf"})
+	p.Register(FormatParserFunc(parseHTML), []string{".html", ".htm", ".xhtml"}, []string{"text/html"})
+	p.Register(FormatParserFunc(parseMHTML), []string{".mht", ".mhtml"}, []string{"multipart/related"})
+	p.Register(FormatParserFunc(parseEPUB), []string{".epub"}, []string{"application/epub+zip"})
+	p.Register(FormatParserFunc(parseCSV), []string{".csv", ".tsv"}, []string{"text/csv", "text/tab-separated-values"})
+	p.Register(FormatParserFunc(parseXLSX), []string{".xlsx", ".xlsm"}, nil)
+	p.Register(FormatParserFunc(parseEML), []string{".eml"}, []string{"message/rfc822"})
+	p.Register(FormatParserFunc(parseMbox), []string{".mbox"}, []string{"application/mbox"})
+
+	return p
 }
 
-// ParseDocument parses a file and returns a Document
-func (p *Parser) ParseDocument(filePath string) (*models.Document, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
+// Register adds a format parser for the given extensions (".ext") and MIME
+// types, replacing any parser previously registered for them
+func (p *Parser) Register(parser FormatParser, extensions []string, mimeTypes []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, ext := range extensions {
+		p.byExt[strings.ToLower(ext)] = parser
+	}
+	for _, mimeType := range mimeTypes {
+		p.byMIME[strings.ToLower(mimeType)] = parser
+	}
+}
+
+// EnableOCR recognizes text in PNG and JPEG images and in PDF pages that
+// have no text layer
+func (p *Parser) EnableOCR(ocr *OCR) {
+	p.Register(FormatParserFunc(ocr.parsePDF), []string{".pdf"}, []string{"application/pdf"})
+	p.Register(FormatParserFunc(ocr.parseImage), []string{".png", ".jpg", ".jpeg"}, []string{"image/png", "image/jpeg"})
+}
+
+// EnableTranscription indexes audio recordings by transcribing them
+func (p *Parser) EnableTranscription(t *Transcriber) {
+	p.Register(FormatParserFunc(t.parseAudio), audioExtensions, []string{"audio/wave", "audio/wav", "audio/mpeg", "audio/mp4"})
+}
 
-	var content string
-	var err error
+// CheckExtensions verifies that every extension has a registered parser
+func (p *Parser) CheckExtensions(extensions []string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 
-	switch ext {
-	case ".pdf":
-		content, err = p.parsePDF(filePath)
-	case ".txt", ".md":
-		content, err = p.parseText(filePath)
-	default:
-		return nil, fmt.Errorf("unsupported file type: %s", ext)
+	var missing []string
+	for _, ext := range extensions {
+		if _, ok := p.byExt[strings.ToLower(ext)]; !ok {
+			missing = append(missing, ext)
+		}
 	}
 
-	if err != nil {
-		return nil, err
+	if len(missing) > 0 {
+		return fmt.Errorf("no parser registered for supported extensions: %s", strings.Join(missing, ", "))
 	}
+	return nil
+}
 
-	// Get file info
+// HasExtension reports whether a parser is registered for ext (".ext")
+func (p *Parser) HasExtension(ext string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.byExt[strings.ToLower(ext)]
+	return ok
+}
+
+// Sniffable reports whether a non-empty file has a parser for its sniffed
+// MIME type
+func (p *Parser) Sniffable(filePath string) bool {
 	info, err := os.Stat(filePath)
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+
+	mimeType, err := sniffMIME(filePath)
+	if err != nil {
+		return false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.byMIME[mimeType]
+	return ok
+}
+
+// ParseDocument parses a file and returns a Document
+func (p *Parser) ParseDocument(filePath string) (*models.Document, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+
+	parser, mimeType, err := p.lookup(filePath, ext)
 	if err != nil {
 		return nil, err
 	}
 
-	// Calculate hash
-	hash, err := utils.FileHash(filePath)
+	// Get file info
+	info, err := os.Stat(filePath)
 	if err != nil {
 		return nil, err
 	}
 
 	doc := &models.Document{
-		ID:          hash,
 		FilePath:    filePath,
-		Content:     content,
-		Hash:        hash,
 		FileModTime: info.ModTime(),
 		Metadata: map[string]string{
 			"filename":  filepath.Base(filePath),
@@ -62,57 +156,92 @@ func (p *Parser) ParseDocument(filePath string) (*models.Document, error) {
 			"size":      fmt.Sprintf("%d", info.Size()),
 		},
 	}
+	if mimeType != "" {
+		doc.Metadata["mime_type"] = mimeType
+	}
 
-	return doc, nil
-}
+	if err := parser.Parse(filePath, doc); err != nil {
+		return nil, err
+	}
 
-func (p *Parser) parsePDF(filePath string) (string, error) {
-	file, reader, err := pdf.Open(filePath)
+	// Calculate hash
+	hash, err := utils.FileHash(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open PDF: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	var content strings.Builder
-	numPages := reader.NumPage()
+	doc.ID = hash
+	doc.Hash = hash
 
-	for i := 1; i <= numPages; i++ {
-		page := reader.Page(i)
-		if page.V.IsNull() {
-			continue
-		}
+	return doc, nil
+}
 
-		text, err := page.GetPlainText(nil)
-		if err != nil {
-			utils.GetLogger().Warnf("Failed to extract text from page %d: %v", i, err)
-			continue
-		}
+// lookup finds the parser for a file by extension, then by sniffed MIME type
+func (p *Parser) lookup(filePath, ext string) (FormatParser, string, error) {
+	p.mu.RLock()
+	parser, ok := p.byExt[ext]
+	p.mu.RUnlock()
+	if ok {
+		return parser, "", nil
+	}
+
+	mimeType, err := sniffMIME(filePath)
+	if err != nil {
+		return nil, "", err
+	}
 
-		content.WriteString(text)
-		content.WriteString("\n")
+	p.mu.RLock()
+	parser, ok = p.byMIME[mimeType]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported file type: %s (%s)", ext, mimeType)
 	}
 
-	return content.String(), nil
+	return parser, mimeType, nil
 }
 
-func (p *Parser) parseText(filePath string) (string, error) {
+// sniffMIME detects the media type of a file from its first bytes
+func sniffMIME(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 
-	var content strings.Builder
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		content.WriteString(scanner.Text())
-		content.WriteString("\n")
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
 	}
 
-	if err := scanner.Err(); err != nil {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
 		return "", err
 	}
+	return mediaType, nil
+}
+
+func parseText(filePath string, doc *models.Document) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Chunk offsets index into the content, so it must be valid UTF-8
+	content := strings.ReplaceAll(decodeText(data), "\r\n", "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	doc.Content = content
+	return nil
+}
+
+func parseCode(filePath string, doc *models.Document) error {
+	if err := parseText(filePath, doc); err != nil {
+		return err
+	}
 
-	return content.String(), nil
+	doc.Metadata["language"] = codeLanguages[doc.Metadata["extension"]]
+	return nil
 }
@@ -0,0 +1,521 @@
+package context
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+var (
+	pdfDateRe    = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?([Zz+\-])?(\d{2})?'?(\d{2})?'?`)
+	pageNumberRe = regexp.MustCompile(`(?i)^[-–—\s]*(page\s*)?\d+(\s*(of|/)\s*\d+)?[-–—\s]*$`)
+	digitsRe     = regexp.MustCompile(`\d+`)
+)
+
+// pdfLine is a run of text on one baseline
+type pdfLine struct {
+	x0, x1 float64
+	y      float64
+	size   float64
+	text   string
+}
+
+// parsePDF extracts text page by page, restoring reading order for
+// multi-column layouts, joining hyphenated line breaks and dropping running
+// headers and footers. Page start offsets go into doc.PageOffsets; pages that
+// can't be read are reported in doc.Warnings.
+func parsePDF(filePath string, doc *models.Document) error {
+	return extractPDF(filePath, doc, nil)
+}
+
+// extractPDF implements parsePDF. When ocr is not nil, pages without a text
+// layer are rendered and recognized instead.
+func extractPDF(filePath string, doc *models.Document, ocr *OCR) error {
+	file, reader, err := pdf.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer file.Close()
+
+	numPages := reader.NumPage()
+	pages := make([][]string, numPages)
+	fonts := make(map[string]*pdf.Font)
+	var ocrPages []string
+
+	for i := 1; i <= numPages; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("page %d: missing from page tree", i))
+			continue
+		}
+
+		lines, err := pageText(page, fonts)
+		if err != nil {
+			utils.GetLogger().Warnf("Failed to extract text from page %d of %s: %v", i, filePath, err)
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("page %d: %v", i, err))
+			continue
+		}
+		if len(lines) == 0 && ocr != nil {
+			result, err := ocr.recognizePage(filePath, i)
+			if err != nil {
+				doc.Warnings = append(doc.Warnings, fmt.Sprintf("page %d: OCR failed: %v", i, err))
+			} else {
+				lines = strings.Split(result.Text, "\n")
+				if doc.OCRConfidence == nil {
+					doc.OCRConfidence = make(map[int]float64)
+				}
+				doc.OCRConfidence[i] = result.Confidence
+				ocrPages = append(ocrPages, strconv.Itoa(i))
+			}
+		}
+		if strings.TrimSpace(strings.Join(lines, "")) == "" {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("page %d: no extractable text", i))
+		}
+		pages[i-1] = lines
+	}
+
+	removeRunningLines(pages)
+
+	var content strings.Builder
+	doc.PageOffsets = make([]int, numPages)
+	for i, lines := range pages {
+		doc.PageOffsets[i] = content.Len()
+
+		text := strings.TrimSpace(strings.Join(dehyphenate(lines), "\n"))
+		if text == "" {
+			continue
+		}
+		content.WriteString(text)
+		content.WriteString("\n\n")
+	}
+
+	doc.Content = content.String()
+	doc.Metadata["pages"] = fmt.Sprintf("%d", numPages)
+	if len(ocrPages) > 0 {
+		doc.Metadata["ocr_pages"] = strings.Join(ocrPages, ", ")
+	}
+	setMetadata(doc.Metadata, pdfInfo(reader))
+
+	return nil
+}
+
+// pdfInfo reads the document information dictionary
+func pdfInfo(reader *pdf.Reader) (info map[string]string) {
+	info = make(map[string]string)
+	defer func() {
+		// A malformed trailer shouldn't fail the whole document
+		recover()
+	}()
+
+	dict := reader.Trailer().Key("Info")
+	if dict.IsNull() {
+		return info
+	}
+
+	for key, name := range map[string]string{
+		"Title":    "title",
+		"Author":   "author",
+		"Subject":  "subject",
+		"Keywords": "keywords",
+	} {
+		info[name] = collapseSpace(dict.Key(key).Text())
+	}
+	info["created"] = pdfDate(dict.Key("CreationDate").Text())
+	info["modified"] = pdfDate(dict.Key("ModDate").Text())
+
+	return info
+}
+
+// pdfDate converts a PDF date string ("D:20240102150405+01'00'") to RFC 3339
+func pdfDate(value string) string {
+	m := pdfDateRe.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return ""
+	}
+
+	num := func(s string, def int) int {
+		n := def
+		if s != "" {
+			fmt.Sscanf(s, "%d", &n)
+		}
+		return n
+	}
+
+	loc := time.UTC
+	if m[7] == "+" || m[7] == "-" {
+		offset := num(m[8], 0)*3600 + num(m[9], 0)*60
+		if m[7] == "-" {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+
+	t := time.Date(num(m[1], 0), time.Month(num(m[2], 1)), num(m[3], 1),
+		num(m[4], 0), num(m[5], 0), num(m[6], 0), 0, loc)
+	return t.Format(time.RFC3339)
+}
+
+// pageText returns the lines of a page in reading order, with blank lines
+// between paragraphs. Pages whose layout can't be read fall back to the
+// library's plain text extraction.
+func pageText(page pdf.Page, fonts map[string]*pdf.Font) ([]string, error) {
+	lines, err := pageLayout(page)
+	if err == nil && len(lines) > 0 {
+		return lines, nil
+	}
+
+	for _, name := range page.Fonts() {
+		if _, ok := fonts[name]; !ok {
+			font := page.Font(name)
+			fonts[name] = &font
+		}
+	}
+	text, plainErr := page.GetPlainText(fonts)
+	if plainErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, plainErr
+	}
+
+	var result []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result, nil
+}
+
+// pageLayout positions the characters of a page into lines and columns
+func pageLayout(page pdf.Page) (lines []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read page content: %v", r)
+		}
+	}()
+
+	segments := textSegments(page.Content().Text)
+	if len(segments) == 0 {
+		return nil, nil
+	}
+	return paragraphLines(orderColumns(segments)), nil
+}
+
+// textSegments groups characters into runs on the same baseline, breaking
+// runs at gaps wide enough to be a column gutter
+func textSegments(chars []pdf.Text) []pdfLine {
+	var filtered []pdf.Text
+	for _, c := range chars {
+		if c.S != "" && c.FontSize > 0 {
+			filtered = append(filtered, c)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].Y > filtered[j].Y })
+
+	// Cluster characters into baselines
+	var rows [][]pdf.Text
+	for _, c := range filtered {
+		if n := len(rows); n > 0 && math.Abs(rows[n-1][0].Y-c.Y) <= 0.3*c.FontSize {
+			rows[n-1] = append(rows[n-1], c)
+			continue
+		}
+		rows = append(rows, []pdf.Text{c})
+	}
+
+	var segments []pdfLine
+	for _, row := range rows {
+		sort.SliceStable(row, func(i, j int) bool { return row[i].X < row[j].X })
+
+		var current *pdfLine
+		var text strings.Builder
+		end := 0.0
+		for _, c := range row {
+			width := c.W
+			if width <= 0 {
+				width = 0.5 * c.FontSize * float64(utf8.RuneCountInString(c.S))
+			}
+
+			gap := c.X - end
+			if current != nil && gap > 1.5*c.FontSize {
+				current.text = strings.TrimSpace(text.String())
+				segments = append(segments, *current)
+				current = nil
+			}
+			if current == nil {
+				current = &pdfLine{x0: c.X, y: row[0].Y, size: c.FontSize}
+				text.Reset()
+			} else if gap > 0.15*c.FontSize && !strings.HasSuffix(text.String(), " ") && c.S != " " {
+				text.WriteString(" ")
+			}
+
+			text.WriteString(c.S)
+			end = c.X + width
+			current.x1 = end
+			current.size = math.Max(current.size, c.FontSize)
+		}
+		if current != nil {
+			current.text = strings.TrimSpace(text.String())
+			segments = append(segments, *current)
+		}
+	}
+
+	return segments
+}
+
+// orderColumns puts segments into reading order. If the page has a vertical
+// gutter that no line crosses, text left of it is read before text right of
+// it; lines spanning the gutter (titles, footers) separate the column bands.
+func orderColumns(segments []pdfLine) []pdfLine {
+	gutter, ok := findGutter(segments)
+	if !ok {
+		return mergeRows(segments)
+	}
+
+	var ordered, left, right []pdfLine
+	flushBand := func() {
+		ordered = append(ordered, mergeRows(left)...)
+		ordered = append(ordered, mergeRows(right)...)
+		left, right = nil, nil
+	}
+
+	for _, s := range segments {
+		switch {
+		case s.x1 <= gutter:
+			left = append(left, s)
+		case s.x0 >= gutter:
+			right = append(right, s)
+		default:
+			flushBand()
+			ordered = append(ordered, s)
+		}
+	}
+	flushBand()
+
+	return ordered
+}
+
+// findGutter looks for an x position in the middle of the page that no more
+// than a few segments cross, with substantial text on both sides
+func findGutter(segments []pdfLine) (float64, bool) {
+	if len(segments) < 6 {
+		return 0, false
+	}
+
+	minX, maxX := segments[0].x0, segments[0].x1
+	for _, s := range segments {
+		minX = math.Min(minX, s.x0)
+		maxX = math.Max(maxX, s.x1)
+	}
+	width := maxX - minX
+	if width <= 0 {
+		return 0, false
+	}
+
+	best, bestCrossing := 0.0, len(segments)
+	for x := minX + 0.25*width; x <= minX+0.75*width; x++ {
+		crossing := 0
+		for _, s := range segments {
+			if s.x0 < x && s.x1 > x {
+				crossing++
+			}
+		}
+		if crossing < bestCrossing {
+			best, bestCrossing = x, crossing
+		}
+	}
+
+	leftCount, rightCount := 0, 0
+	for _, s := range segments {
+		if s.x1 <= best {
+			leftCount++
+		} else if s.x0 >= best {
+			rightCount++
+		}
+	}
+
+	minSide := max(3, len(segments)/5)
+	if bestCrossing > len(segments)/10 || leftCount < minSide || rightCount < minSide {
+		return 0, false
+	}
+	return best, true
+}
+
+// mergeRows joins segments that share a baseline (in x order), keeping the
+// top-to-bottom order of the rows
+func mergeRows(segments []pdfLine) []pdfLine {
+	var merged []pdfLine
+	for _, s := range segments {
+		if n := len(merged); n > 0 && math.Abs(merged[n-1].y-s.y) <= 0.3*s.size {
+			last := &merged[n-1]
+			last.text += " " + s.text
+			last.x0 = math.Min(last.x0, s.x0)
+			last.x1 = math.Max(last.x1, s.x1)
+			last.size = math.Max(last.size, s.size)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// paragraphLines renders ordered lines, inserting a blank line where the
+// vertical gap suggests a new paragraph or where reading jumps to a new column
+func paragraphLines(lines []pdfLine) []string {
+	var result []string
+	for i, line := range lines {
+		if line.text == "" {
+			continue
+		}
+		if i > 0 {
+			gap := lines[i-1].y - line.y
+			if gap < 0 || gap > 1.6*math.Max(line.size, lines[i-1].size) {
+				result = append(result, "")
+			}
+		}
+		result = append(result, line.text)
+	}
+	return result
+}
+
+// removeRunningLines drops headers and footers: lines near the top or bottom
+// of a page that repeat (ignoring digits) on at least half the pages, and bare
+// page numbers
+func removeRunningLines(pages [][]string) {
+	if len(pages) < 2 {
+		return
+	}
+
+	const edge = 2 // lines at each end of a page that may be headers or footers
+
+	normalize := func(line string) string {
+		return collapseSpace(strings.ToLower(digitsRe.ReplaceAllString(line, "#")))
+	}
+
+	// Short pages contribute fewer edge lines so their body isn't mistaken for a header
+	edgeLines := func(lines []string) []int {
+		nonEmpty := 0
+		for _, line := range lines {
+			if line != "" {
+				nonEmpty++
+			}
+		}
+		limit := min(edge, nonEmpty/3)
+
+		var idx []int
+		for i, n := 0, 0; i < len(lines) && n < limit; i++ {
+			if lines[i] != "" {
+				idx = append(idx, i)
+				n++
+			}
+		}
+		for i, n := len(lines)-1, 0; i >= 0 && n < limit; i-- {
+			if lines[i] != "" {
+				idx = append(idx, i)
+				n++
+			}
+		}
+		return idx
+	}
+
+	counts := make(map[string]int)
+	for _, lines := range pages {
+		seen := make(map[string]bool)
+		for _, i := range edgeLines(lines) {
+			key := normalize(lines[i])
+			if !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+
+	threshold := max(3, (len(pages)+1)/2)
+	for p, lines := range pages {
+		drop := make(map[int]bool)
+		for _, i := range edgeLines(lines) {
+			if counts[normalize(lines[i])] >= threshold || pageNumberRe.MatchString(lines[i]) {
+				drop[i] = true
+			}
+		}
+		if len(drop) == 0 {
+			continue
+		}
+
+		kept := lines[:0]
+		for i, line := range lines {
+			if !drop[i] {
+				kept = append(kept, line)
+			}
+		}
+		pages[p] = kept
+	}
+}
+
+// dehyphenate joins words broken across lines ("infor-" / "mation") by
+// moving the rest of the word up to the first line
+func dehyphenate(lines []string) []string {
+	result := append([]string(nil), lines...)
+	for i := 0; i+1 < len(result); i++ {
+		line, next := result[i], result[i+1]
+		if len(line) < 2 || !strings.HasSuffix(line, "-") || next == "" {
+			continue
+		}
+
+		before, _ := utf8.DecodeLastRuneInString(line[:len(line)-1])
+		after, _ := utf8.DecodeRuneInString(next)
+		if !unicode.IsLetter(before) || !unicode.IsLower(after) {
+			continue
+		}
+
+		word, rest, _ := strings.Cut(next, " ")
+		result[i] = line[:len(line)-1] + word
+		result[i+1] = strings.TrimSpace(rest)
+	}
+
+	// Drop lines emptied by joining, keeping paragraph breaks
+	kept := result[:0]
+	for i, line := range result {
+		if line != "" || (i > 0 && lines[i] == "") {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// annotatePages records the pages each chunk starts and ends on, from the
+// offsets in the document text its chunker recorded
+func annotatePages(doc *models.Document, chunks []*models.Chunk) {
+	for _, chunk := range chunks {
+		start, ok := chunk.Metadata["offset"].(int)
+		if !ok {
+			continue
+		}
+		end, ok := chunk.Metadata["offset_end"].(int)
+		if !ok || end <= start {
+			continue
+		}
+
+		chunk.Metadata["page"] = pageAt(doc.PageOffsets, start)
+		chunk.Metadata["page_end"] = pageAt(doc.PageOffsets, end-1)
+	}
+}
+
+// pageAt returns the 1-based page containing a byte offset
+func pageAt(offsets []int, pos int) int {
+	return sort.Search(len(offsets), func(i int) bool { return offsets[i] > pos })
+}
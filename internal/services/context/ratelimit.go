@@ -0,0 +1,74 @@
+package context
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token-bucket rate limiter. A nil bucket never blocks.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+// newTokenBucket creates a bucket that refills perMinute tokens every minute.
+// It returns nil when perMinute is not positive, which disables limiting.
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60.0,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until n tokens are available or ctx is done
+func (b *tokenBucket) Wait(ctx context.Context, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	need := float64(n)
+	if need > b.capacity {
+		// A single request larger than the bucket would never fit; let it
+		// through once the bucket is full instead of blocking forever.
+		need = b.capacity
+	}
+
+	for {
+		b.mu.Lock()
+		b.refill()
+		if b.tokens >= need {
+			b.tokens -= need
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (b *tokenBucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
@@ -0,0 +1,409 @@
+package context
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// rtfSkipDestinations are groups whose content is not document text
+var rtfSkipDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "pict": true, "object": true,
+	"header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true,
+	"footnote": true, "annotation": true, "fldinst": true, "listtable": true,
+	"listoverridetable": true, "listtext": true, "pntext": true, "pntxta": true, "pntxtb": true,
+	"rsidtbl": true, "generator": true, "themedata": true, "colorschememapping": true,
+	"latentstyles": true, "datastore": true, "xmlnstbl": true, "filetbl": true,
+	"revtbl": true, "mmathPr": true, "bkmkstart": true, "bkmkend": true,
+}
+
+// rtfInfoFields maps \info subgroups to metadata keys
+var rtfInfoFields = map[string]string{
+	"title":    "title",
+	"subject":  "subject",
+	"author":   "author",
+	"keywords": "keywords",
+	"doccomm":  "description",
+	"operator": "last_modified_by",
+	"creatim":  "created",
+	"revtim":   "modified",
+}
+
+// rtfSymbols maps control words to the characters they stand for
+var rtfSymbols = map[string]string{
+	"emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+	"emspace": " ", "enspace": " ", "qmspace": " ",
+}
+
+// cp1252 maps the Windows-1252 bytes 0x80-0x9F that differ from Latin-1
+var cp1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+var rtfHeadingStyleRe = regexp.MustCompile(`(?i)^heading\s*(\d)`)
+
+// rtfGroup is the parser state saved on '{' and restored on '}'
+type rtfGroup struct {
+	dest string // destination the group belongs to, empty for body text
+	skip bool
+	uc   int // characters to skip after \uN
+}
+
+// rtfDocument accumulates text and properties while walking an RTF stream
+type rtfDocument struct {
+	out  markdownWriter
+	meta map[string]string
+
+	para      strings.Builder
+	level     int // heading level of the current paragraph
+	style     int
+	listDepth int // -1 if the paragraph is not a list item
+	inTable   bool
+	row       []string
+	cell      strings.Builder
+
+	styleLevels map[int]int
+	styleName   strings.Builder
+	dest        strings.Builder // text of the current \info field
+	date        map[string]int  // \yr, \mo, ... of the current date field
+}
+
+// parseRTF extracts text from a Rich Text Format document, rendering
+// headings, lists and tables as Markdown
+func parseRTF(filePath string, doc *models.Document) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(data, []byte("{\\rtf")) {
+		return fmt.Errorf("failed to parse RTF: missing {\\rtf header")
+	}
+
+	content, meta := rtfText(data)
+
+	doc.Content = content
+	doc.Metadata["content_format"] = "markdown"
+	setMetadata(doc.Metadata, meta)
+
+	return nil
+}
+
+// rtfText tokenizes an RTF stream, returning the body as Markdown and the
+// \info properties
+func rtfText(data []byte) (string, map[string]string) {
+	d := &rtfDocument{
+		meta:        make(map[string]string),
+		styleLevels: make(map[int]int),
+		listDepth:   -1,
+	}
+
+	state := rtfGroup{uc: 1}
+	var stack []rtfGroup
+	skipChars := 0 // fallback characters still to skip after \uN
+	groupStart := false
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch c {
+		case '{':
+			stack = append(stack, state)
+			groupStart = true
+			skipChars = 0
+			continue
+		case '}':
+			d.endGroup(state)
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+			groupStart = false
+			skipChars = 0
+			continue
+		case '\r', '\n':
+			continue
+		}
+
+		if c != '\\' {
+			if skipChars > 0 {
+				skipChars--
+				continue
+			}
+			d.write(state, decodeCP1252(c))
+			groupStart = false
+			continue
+		}
+
+		// Control symbol or control word
+		if i+1 >= len(data) {
+			break
+		}
+		next := data[i+1]
+
+		if !isASCIILetter(next) {
+			i++
+			switch next {
+			case '\'':
+				if i+2 < len(data) {
+					if b, err := strconv.ParseUint(string(data[i+1:i+3]), 16, 8); err == nil {
+						if skipChars > 0 {
+							skipChars--
+						} else {
+							d.write(state, decodeCP1252(byte(b)))
+						}
+					}
+					i += 2
+				}
+			case '*':
+				// Ignorable destination: skip unless it is one we understand
+				state.skip = true
+				state.dest = "*"
+			case '~':
+				d.write(state, ' ')
+			case '_':
+				d.write(state, '-')
+			case '\\', '{', '}':
+				d.write(state, rune(next))
+			case '\r', '\n':
+				d.control(&state, "par", 0, false)
+			}
+			groupStart = false
+			continue
+		}
+
+		// Control word: letters, optional signed number, optional space delimiter
+		j := i + 1
+		for j < len(data) && isASCIILetter(data[j]) {
+			j++
+		}
+		word := string(data[i+1 : j])
+
+		k := j
+		if k < len(data) && data[k] == '-' {
+			k++
+		}
+		for k < len(data) && data[k] >= '0' && data[k] <= '9' {
+			k++
+		}
+		param, hasParam := 0, false
+		if k > j && data[k-1] != '-' {
+			param, _ = strconv.Atoi(string(data[j:k]))
+			hasParam = true
+		} else {
+			k = j
+		}
+		if k < len(data) && data[k] == ' ' {
+			k++
+		}
+		i = k - 1
+
+		if word == "u" && hasParam {
+			if param < 0 {
+				param += 65536
+			}
+			d.write(state, rune(param))
+			skipChars = state.uc
+			groupStart = false
+			continue
+		}
+
+		if groupStart || state.dest == "*" {
+			d.startDestination(&state, word)
+		}
+		groupStart = false
+
+		d.control(&state, word, param, hasParam)
+	}
+
+	d.endParagraph()
+	d.out.EndTable()
+
+	return d.out.String(), d.meta
+}
+
+// startDestination handles the first control word of a group
+func (d *rtfDocument) startDestination(state *rtfGroup, word string) {
+	switch {
+	case word == "info" || word == "stylesheet":
+		state.dest, state.skip = word, false
+	case state.dest == "info" && rtfInfoFields[word] != "":
+		state.dest, state.skip = word, false
+		d.dest.Reset()
+		d.date = make(map[string]int)
+	case state.dest == "stylesheet":
+		// Each style definition is a subgroup of the stylesheet
+		state.dest = "style"
+		d.style = 0
+		d.styleName.Reset()
+	case rtfSkipDestinations[word]:
+		state.dest, state.skip = word, true
+	}
+}
+
+// control applies one control word
+func (d *rtfDocument) control(state *rtfGroup, word string, param int, hasParam bool) {
+	switch word {
+	case "uc":
+		state.uc = param
+		return
+	case "yr", "mo", "dy", "hr", "min":
+		if d.date != nil {
+			d.date[word] = param
+		}
+		return
+	}
+
+	if state.skip || state.dest == "info" {
+		return
+	}
+
+	if state.dest == "style" {
+		if word == "s" && hasParam {
+			d.style = param
+		}
+		return
+	}
+
+	switch word {
+	case "par", "sect", "page":
+		d.endParagraph()
+	case "pard":
+		d.level, d.style, d.listDepth, d.inTable = 0, 0, -1, false
+	case "s":
+		d.style = param
+		d.level = d.styleLevels[param]
+	case "outlinelevel":
+		if param >= 0 && param < 9 {
+			d.level = param + 1
+		}
+	case "ls":
+		if d.listDepth < 0 {
+			d.listDepth = 0
+		}
+	case "ilvl":
+		d.listDepth = param
+	case "intbl":
+		d.inTable = true
+	case "cell", "nestcell":
+		d.endParagraph()
+		d.row = append(d.row, d.cell.String())
+		d.cell.Reset()
+	case "row", "nestrow":
+		d.out.TableRow(d.row)
+		d.row = nil
+	case "line":
+		d.para.WriteString("\n")
+	case "tab":
+		d.para.WriteString("\t")
+	default:
+		if symbol, ok := rtfSymbols[word]; ok {
+			d.para.WriteString(symbol)
+		}
+	}
+}
+
+// write adds a character to the current destination
+func (d *rtfDocument) write(state rtfGroup, r rune) {
+	switch {
+	case state.skip || state.dest == "info":
+	case state.dest == "style":
+		d.styleName.WriteRune(r)
+	case rtfInfoFields[state.dest] != "":
+		d.dest.WriteRune(r)
+	default:
+		d.para.WriteRune(r)
+	}
+}
+
+// endGroup stores style names and \info fields when their group closes
+func (d *rtfDocument) endGroup(state rtfGroup) {
+	switch {
+	case state.dest == "style":
+		name := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(d.styleName.String()), ";"))
+		if m := rtfHeadingStyleRe.FindStringSubmatch(name); m != nil {
+			d.styleLevels[d.style], _ = strconv.Atoi(m[1])
+		} else if strings.EqualFold(name, "title") {
+			d.styleLevels[d.style] = 1
+		}
+		d.style = 0
+	case rtfInfoFields[state.dest] != "":
+		key := rtfInfoFields[state.dest]
+		value := strings.TrimSpace(d.dest.String())
+		if key == "created" || key == "modified" {
+			value = rtfDate(d.date)
+		}
+		if value != "" {
+			d.meta[key] = value
+		}
+		d.date = nil
+	}
+}
+
+// endParagraph writes the current paragraph as a heading, list item,
+// table cell text or plain paragraph
+func (d *rtfDocument) endParagraph() {
+	text := d.para.String()
+	d.para.Reset()
+
+	switch {
+	case d.inTable:
+		if strings.TrimSpace(text) != "" {
+			if d.cell.Len() > 0 {
+				d.cell.WriteString(" ")
+			}
+			d.cell.WriteString(text)
+		}
+		return
+	case d.level > 0:
+		d.out.Heading(d.level, text)
+	case d.listDepth >= 0:
+		d.out.ListItem(strings.TrimLeftFunc(text, func(r rune) bool {
+			return r == '•' || r == '·' || unicode.IsSpace(r)
+		}), d.listDepth)
+	default:
+		d.out.Paragraph(text)
+	}
+
+	// A table ends at the first paragraph outside it
+	d.out.EndTable()
+}
+
+// rtfDate formats the \yr\mo\dy\hr\min fields of an \info date as RFC 3339
+func rtfDate(fields map[string]int) string {
+	if fields["yr"] == 0 {
+		return ""
+	}
+	month, day := fields["mo"], fields["dy"]
+	if month == 0 {
+		month = 1
+	}
+	if day == 0 {
+		day = 1
+	}
+	t := time.Date(fields["yr"], time.Month(month), day, fields["hr"], fields["min"], 0, 0, time.UTC)
+	return t.Format(time.RFC3339)
+}
+
+// decodeCP1252 converts a Windows-1252 byte to a rune
+func decodeCP1252(b byte) rune {
+	if r, ok := cp1252[b]; ok {
+		return r
+	}
+	return rune(b)
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
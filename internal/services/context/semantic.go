@@ -0,0 +1,162 @@
+package context
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+const defaultSemanticBreakpoint = 90.0
+
+// chunkSemantic groups consecutive sentences and starts a new chunk where the
+// embedding similarity between neighbouring sentences drops sharply.
+// Chunks never exceed size tokens and are not split before reaching minSize tokens.
+func (e *Embedder) chunkSemantic(doc *models.Document, text string, size, minSize int) []*models.Chunk {
+	// Sentences are compared with normalized whitespace, keeping where they
+	// came from in text
+	var sentences []string
+	var spans [][2]int
+	add := func(sent string, start, end int) {
+		if sent = strings.Join(strings.Fields(sent), " "); sent != "" {
+			sentences = append(sentences, sent)
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+
+	offset := 0
+	for _, para := range strings.Split(text, "\n\n") {
+		paraStart := offset
+		offset += len(para) + len("\n\n")
+
+		for _, span := range sentenceSpans(para) {
+			sent := para[span[0]:span[1]]
+			sentStart := paraStart + span[0]
+
+			// A sentence longer than a chunk is cut into pieces that fit
+			if e.tokenizer.Count(sent) > size {
+				for _, span := range e.tokenSpans(sent, size, 0) {
+					add(sent[span[0]:span[1]], sentStart+span[0], sentStart+span[1])
+				}
+				continue
+			}
+			add(sent, sentStart, sentStart+len(sent))
+		}
+	}
+
+	if len(sentences) < 2 {
+		return e.chunkRecursive(doc, text, size, e.cfg.Context.Chunking.ChunkOverlap)
+	}
+
+	// Sentence vectors are only used to find breakpoints, so they bypass the
+	// embedding cache rather than filling it with entries never looked up again
+	embeddings, err := e.embedBatches(sentences)
+	if err != nil {
+		utils.GetLogger().Warnf("Semantic chunking unavailable for %s, using recursive: %v", doc.FilePath, err)
+		return e.chunkRecursive(doc, text, size, e.cfg.Context.Chunking.ChunkOverlap)
+	}
+
+	// distances[i] is the semantic distance between sentence i and i+1
+	distances := make([]float64, len(sentences)-1)
+	for i := range distances {
+		distances[i] = 1 - vectorSimilarity(embeddings[i], embeddings[i+1])
+	}
+
+	breakpoint := e.cfg.Context.Chunking.SemanticBreakpoint
+	if breakpoint == 0 {
+		breakpoint = defaultSemanticBreakpoint
+	}
+	cutoff := percentile(distances, breakpoint)
+
+	var chunks []*models.Chunk
+	var current strings.Builder
+	currentTokens := 0
+	first := 0 // first sentence of the current chunk
+
+	flush := func(end int) {
+		chunk := newChunk(doc, current.String(), len(chunks))
+		setSpan(chunk, text, spans[first][0], spans[end][1])
+		chunks = append(chunks, chunk)
+		current.Reset()
+		currentTokens = 0
+	}
+
+	for i, sent := range sentences {
+		sentTokens := e.tokenizer.Count(sent)
+
+		if current.Len() > 0 {
+			topicShift := distances[i-1] >= cutoff && currentTokens >= minSize
+			tooLarge := currentTokens+sentTokens > size
+			if topicShift || tooLarge {
+				flush(i - 1)
+			}
+		}
+
+		if current.Len() > 0 {
+			current.WriteString(" ")
+		} else {
+			first = i
+		}
+		current.WriteString(sent)
+		currentTokens += sentTokens
+	}
+
+	if current.Len() > 0 {
+		flush(len(sentences) - 1)
+	}
+
+	return chunks
+}
+
+// embedBatches embeds texts in batches of the configured size, without
+// going through the embedding cache
+func (e *Embedder) embedBatches(texts []string) ([][]float32, error) {
+	batchSize := max(e.cfg.Context.Embeddings.BatchSize, 1)
+
+	embeddings := make([][]float32, 0, len(texts))
+	for i := 0; i < len(texts); i += batchSize {
+		batch, err := e.EmbedTexts(texts[i:min(i+batchSize, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+// vectorSimilarity returns the cosine similarity of two embeddings
+func vectorSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentile returns the p-th percentile (0-100) of values
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
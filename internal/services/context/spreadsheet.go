@@ -0,0 +1,258 @@
+package context
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// Spreadsheets are rendered one line per row, every value labeled with its
+// column header, so a row is meaningful on its own:
+//
+//	Sheet: Budget
+//	Columns: Quarter | Team | Spend
+//	Row 2: Quarter: Q2 | Team: Marketing | Spend: 12000
+const (
+	sheetPrefix   = "Sheet: "
+	columnsPrefix = "Columns: "
+)
+
+var sheetRowRe = regexp.MustCompile(`^Row (\d+): `)
+
+// sheetRow is one spreadsheet row and its 1-based row number
+type sheetRow struct {
+	number int
+	cells  []string
+}
+
+// isTabular reports whether a document should use the row chunker
+func isTabular(doc *models.Document) bool {
+	return doc.Metadata["content_format"] == "rows"
+}
+
+// chunkRows splits rendered spreadsheets into groups of whole rows. Every
+// chunk starts with its sheet name and column headers.
+func (e *Embedder) chunkRows(doc *models.Document, text string, size int) []*models.Chunk {
+	var chunks []*models.Chunk
+
+	var sheet, columns string
+	var rows []string
+	firstRow, lastRow, tokens := 0, 0, 0
+
+	header := func() string {
+		return sheetPrefix + sheet + "\n" + columnsPrefix + columns
+	}
+
+	flush := func() {
+		if len(rows) > 0 {
+			chunk := newChunk(doc, header()+"\n"+strings.Join(rows, "\n"), len(chunks))
+			chunk.Metadata["sheet"] = sheet
+			chunk.Metadata["start_row"] = firstRow
+			chunk.Metadata["end_row"] = lastRow
+			chunks = append(chunks, chunk)
+		}
+		rows, tokens = nil, 0
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, sheetPrefix):
+			flush()
+			sheet, columns = strings.TrimPrefix(line, sheetPrefix), ""
+		case strings.HasPrefix(line, columnsPrefix):
+			flush()
+			columns = strings.TrimPrefix(line, columnsPrefix)
+		case sheetRowRe.MatchString(line):
+			number, _ := strconv.Atoi(sheetRowRe.FindStringSubmatch(line)[1])
+			budget := size - e.tokenizer.Count(header())
+			if budget < size/2 {
+				budget = size / 2
+			}
+
+			lineTokens := e.tokenizer.Count(line) + 1
+			if tokens+lineTokens > budget && len(rows) > 0 {
+				flush()
+			}
+
+			// A single row wider than the budget is split between its values
+			if lineTokens > budget {
+				for _, part := range e.splitRow(line, budget) {
+					rows, firstRow, lastRow = []string{part}, number, number
+					flush()
+				}
+				continue
+			}
+
+			if len(rows) == 0 {
+				firstRow = number
+			}
+			rows = append(rows, line)
+			lastRow = number
+			tokens += lineTokens
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// splitRow splits a rendered row into parts of at most budget tokens at
+// value boundaries, repeating the "Row N:" label on each part
+func (e *Embedder) splitRow(line string, budget int) []string {
+	label := sheetRowRe.FindString(line)
+	budget -= e.tokenizer.Count(label)
+
+	var parts, current []string
+	tokens := 0
+	flush := func() {
+		if len(current) > 0 {
+			parts = append(parts, label+strings.Join(current, " | "))
+		}
+		current, tokens = nil, 0
+	}
+
+	for _, value := range strings.Split(strings.TrimPrefix(line, label), " | ") {
+		valueTokens := e.tokenizer.Count(value) + 1
+		if tokens+valueTokens > budget {
+			flush()
+		}
+		if valueTokens > budget {
+			for _, window := range e.splitByTokens(value, budget, 0) {
+				parts = append(parts, label+window)
+			}
+			continue
+		}
+		current = append(current, value)
+		tokens += valueTokens
+	}
+	flush()
+
+	return parts
+}
+
+// writeSheet renders a sheet, using its first non-empty row as the header
+func writeSheet(out *strings.Builder, name string, rows []sheetRow) int {
+	var headers []string
+	written := 0
+
+	for _, row := range rows {
+		if isEmptyRow(row.cells) {
+			continue
+		}
+
+		if headers == nil {
+			headers = make([]string, len(row.cells))
+			for i, cell := range row.cells {
+				headers[i] = collapseSpace(cell)
+				if headers[i] == "" {
+					headers[i] = columnName(i)
+				}
+			}
+
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString(sheetPrefix + collapseSpace(name) + "\n")
+			out.WriteString(columnsPrefix + strings.Join(headers, " | ") + "\n")
+			continue
+		}
+
+		var values []string
+		for i, cell := range row.cells {
+			cell = collapseSpace(cell)
+			if cell == "" {
+				continue
+			}
+			label := columnName(i)
+			if i < len(headers) {
+				label = headers[i]
+			}
+			values = append(values, label+": "+cell)
+		}
+
+		fmt.Fprintf(out, "Row %d: %s\n", row.number, strings.Join(values, " | "))
+		written++
+	}
+
+	return written
+}
+
+func isEmptyRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// columnName returns the spreadsheet letter name of a 0-based column index
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// parseCSV reads a comma, semicolon or tab separated file as a single sheet
+func parseCSV(filePath string, doc *models.Document) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	text := strings.TrimPrefix(decodeText(data), "\ufeff")
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = csvDelimiter(doc.Metadata["extension"], text)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	var rows []sheetRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse CSV: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		rows = append(rows, sheetRow{number: line, cells: record})
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+
+	var content strings.Builder
+	count := writeSheet(&content, name, rows)
+
+	doc.Content = content.String()
+	doc.Metadata["content_format"] = "rows"
+	doc.Metadata["sheets"] = name
+	doc.Metadata["rows"] = strconv.Itoa(count)
+
+	return nil
+}
+
+// csvDelimiter picks the delimiter that occurs most in the first line
+func csvDelimiter(ext, text string) rune {
+	if ext == ".tsv" {
+		return '\t'
+	}
+
+	firstLine, _, _ := strings.Cut(text, "\n")
+	best, bestCount := ',', 0
+	for _, delim := range []rune{',', ';', '\t', '|'} {
+		if n := strings.Count(firstLine, string(delim)); n > bestCount {
+			best, bestCount = delim, n
+		}
+	}
+	return best
+}
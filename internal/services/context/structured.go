@@ -0,0 +1,198 @@
+package context
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxArchiveEntrySize bounds how much is read from one entry of a zip-based
+// document format, guarding against decompression bombs
+const maxArchiveEntrySize = 64 << 20
+
+// markdownWriter renders extracted document structure as Markdown, so
+// structured formats are chunked by the Markdown chunker with heading paths
+type markdownWriter struct {
+	b        strings.Builder
+	rows     int
+	inTable  bool
+	lastList bool
+}
+
+// Heading writes a heading of the given level (1-6)
+func (w *markdownWriter) Heading(level int, text string) {
+	text = collapseSpace(text)
+	if text == "" {
+		return
+	}
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	w.block(strings.Repeat("#", level) + " " + text)
+}
+
+// Paragraph writes a paragraph of text
+func (w *markdownWriter) Paragraph(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	// Keep paragraph text from being read back as a heading
+	if strings.HasPrefix(text, "#") {
+		text = "\\" + text
+	}
+	w.block(text)
+}
+
+// ListItem writes a bullet list item at the given nesting depth
+func (w *markdownWriter) ListItem(text string, depth int) {
+	text = collapseSpace(text)
+	if text == "" {
+		return
+	}
+	w.endTable()
+	if !w.lastList && w.b.Len() > 0 {
+		w.b.WriteString("\n")
+	}
+	w.b.WriteString(strings.Repeat("  ", depth) + "- " + text + "\n")
+	w.lastList = true
+}
+
+// CodeBlock writes preformatted text as a fenced code block
+func (w *markdownWriter) CodeBlock(text string) {
+	text = strings.Trim(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	w.block(fence + "\n" + text + "\n" + fence)
+}
+
+// TableRow writes a table row; the first row of a table becomes its header
+func (w *markdownWriter) TableRow(cells []string) {
+	if len(cells) == 0 {
+		return
+	}
+	for i, cell := range cells {
+		cells[i] = strings.ReplaceAll(collapseSpace(cell), "|", "\\|")
+	}
+
+	if !w.inTable {
+		w.separate()
+		w.inTable = true
+		w.rows = 0
+	}
+
+	w.b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	if w.rows == 0 {
+		w.b.WriteString(strings.Repeat("|---", len(cells)) + "|\n")
+	}
+	w.rows++
+}
+
+// EndTable finishes the current table
+func (w *markdownWriter) EndTable() {
+	w.endTable()
+}
+
+func (w *markdownWriter) String() string {
+	return strings.TrimSpace(w.b.String()) + "\n"
+}
+
+func (w *markdownWriter) block(text string) {
+	w.endTable()
+	w.separate()
+	w.b.WriteString(text + "\n")
+	w.lastList = false
+}
+
+func (w *markdownWriter) separate() {
+	if w.b.Len() > 0 {
+		w.b.WriteString("\n")
+	}
+	w.lastList = false
+}
+
+func (w *markdownWriter) endTable() {
+	w.inTable = false
+}
+
+// collapseSpace joins all whitespace runs into single spaces
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// readZipEntry reads a named entry from a zip archive
+func readZipEntry(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(io.LimitReader(rc, maxArchiveEntrySize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxArchiveEntrySize {
+			return nil, fmt.Errorf("%s exceeds %d bytes", name, maxArchiveEntrySize)
+		}
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
+
+// normalizeDate converts common document date formats to RFC 3339,
+// returning the input unchanged if it can't be parsed
+func normalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		time.RFC1123Z,
+		time.RFC1123,
+	} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+// decodeText returns data as UTF-8, treating invalid UTF-8 as Windows-1252
+func decodeText(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = decodeCP1252(b)
+	}
+	return string(runes)
+}
+
+// setMetadata stores non-empty values in doc metadata
+func setMetadata(metadata map[string]string, values map[string]string) {
+	for key, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			metadata[key] = value
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package context
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// Tokenizer converts text to model tokens
+type Tokenizer interface {
+	// Encode returns the token ids for text
+	Encode(text string) []int
+	// Decode returns the text for token ids
+	Decode(tokens []int) string
+	// Count returns the number of tokens in text
+	Count(text string) int
+}
+
+var (
+	tokenizersMu sync.Mutex
+	tokenizers   = make(map[string]Tokenizer) // vocab path -> tokenizer
+)
+
+// NewTokenizer returns the tokenizer configured for chunking. Vocab files are
+// loaded once per path. Without a vocab file it falls back to an estimate so
+// indexing keeps working, with a warning.
+func NewTokenizer(cfg *config.Config) Tokenizer {
+	path := cfg.Context.Chunking.TokenizerVocab
+
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+
+	if tok, ok := tokenizers[path]; ok {
+		return tok
+	}
+
+	var tok Tokenizer
+	bpe, err := LoadBPETokenizer(path)
+	if err != nil {
+		utils.GetLogger().Warnf("Tokenizer vocab unavailable, estimating token counts: %v", err)
+		tok = &approxTokenizer{}
+	} else {
+		tok = bpe
+	}
+
+	tokenizers[path] = tok
+	return tok
+}
+
+// BPETokenizer is a byte-level BPE tokenizer compatible with tiktoken
+// encodings such as cl100k_base
+type BPETokenizer struct {
+	ranks   map[string]int
+	decoder map[int]string
+}
+
+// LoadBPETokenizer loads a tiktoken vocab file ("<base64 token> <rank>" per line)
+func LoadBPETokenizer(path string) (*BPETokenizer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no vocab file configured")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocab file: %w", err)
+	}
+	defer file.Close()
+
+	tok := &BPETokenizer{
+		ranks:   make(map[string]int),
+		decoder: make(map[int]string),
+	}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid vocab entry on line %d", line)
+		}
+
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token on line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank on line %d: %w", line, err)
+		}
+
+		tok.ranks[string(token)] = rank
+		tok.decoder[rank] = string(token)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocab file: %w", err)
+	}
+
+	if len(tok.ranks) == 0 {
+		return nil, fmt.Errorf("vocab file is empty: %s", path)
+	}
+
+	return tok, nil
+}
+
+// Encode returns the token ids for text
+func (t *BPETokenizer) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range pretokenize(text) {
+		tokens = append(tokens, t.encodePiece(piece)...)
+	}
+	return tokens
+}
+
+// Decode returns the text for token ids. Token sequences that split a
+// multi-byte character decode with the partial character replaced.
+func (t *BPETokenizer) Decode(tokens []int) string {
+	var buf strings.Builder
+	for _, token := range tokens {
+		buf.WriteString(t.decoder[token])
+	}
+	return strings.ToValidUTF8(buf.String(), "�")
+}
+
+// Count returns the number of tokens in text
+func (t *BPETokenizer) Count(text string) int {
+	count := 0
+	for _, piece := range pretokenize(text) {
+		count += t.countPiece(piece)
+	}
+	return count
+}
+
+func (t *BPETokenizer) countPiece(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+	return len(t.mergePiece(piece))
+}
+
+func (t *BPETokenizer) encodePiece(piece string) []int {
+	if rank, ok := t.ranks[piece]; ok {
+		return []int{rank}
+	}
+
+	parts := t.mergePiece(piece)
+	tokens := make([]int, 0, len(parts))
+	for _, part := range parts {
+		if rank, ok := t.ranks[part]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		// Every byte is in a complete byte-level vocab; be lenient with partial ones
+		for i := 0; i < len(part); i++ {
+			tokens = append(tokens, t.ranks[part[i:i+1]])
+		}
+	}
+	return tokens
+}
+
+// mergePiece applies byte pair merges in rank order until none apply
+func (t *BPETokenizer) mergePiece(piece string) []string {
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+
+	return parts
+}
+
+// approxTokenizer estimates token counts when no vocab is available.
+// It cannot produce real token ids, so Encode returns one id per estimated token.
+type approxTokenizer struct{}
+
+func (approxTokenizer) Encode(text string) []int {
+	return make([]int, approxTokenizer{}.Count(text))
+}
+
+func (approxTokenizer) Decode(tokens []int) string {
+	return ""
+}
+
+func (approxTokenizer) Count(text string) int {
+	count := 0
+	for _, piece := range pretokenize(text) {
+		count += (len(piece) + 3) / 4
+	}
+	return count
+}
+
+// pretokenize splits text into the pieces BPE merges are applied within.
+// It is a hand-written equivalent of the cl100k_base pattern
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// since Go's regexp package has no lookahead.
+func pretokenize(text string) []string {
+	var pieces []string
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		end := matchPiece(runes, i)
+		pieces = append(pieces, string(runes[i:end]))
+		i = end
+	}
+
+	return pieces
+}
+
+// matchPiece returns the end of the piece starting at i
+func matchPiece(runes []rune, i int) int {
+	r := runes[i]
+	n := len(runes)
+
+	// Contractions
+	if r == '\'' && i+1 < n {
+		next := unicode.ToLower(runes[i+1])
+		if next == 's' || next == 't' || next == 'm' || next == 'd' {
+			return i + 2
+		}
+		if i+2 < n {
+			pair := string([]rune{next, unicode.ToLower(runes[i+2])})
+			if pair == "re" || pair == "ve" || pair == "ll" {
+				return i + 3
+			}
+		}
+	}
+
+	// Letters, optionally preceded by one non-letter, non-digit, non-newline
+	if unicode.IsLetter(r) {
+		return scanWhile(runes, i+1, unicode.IsLetter)
+	}
+	if !isNewline(r) && !unicode.IsNumber(r) && i+1 < n && unicode.IsLetter(runes[i+1]) {
+		return scanWhile(runes, i+2, unicode.IsLetter)
+	}
+
+	// Up to three digits
+	if unicode.IsNumber(r) {
+		end := i + 1
+		for end < n && end < i+3 && unicode.IsNumber(runes[end]) {
+			end++
+		}
+		return end
+	}
+
+	// Punctuation run, optionally preceded by a space, plus trailing newlines
+	start := i
+	if r == ' ' && i+1 < n && isSymbol(runes[i+1]) {
+		start = i + 1
+	}
+	if isSymbol(runes[start]) {
+		end := scanWhile(runes, start+1, isSymbol)
+		return scanWhile(runes, end, isNewline)
+	}
+
+	// Whitespace
+	end := scanWhile(runes, i+1, unicode.IsSpace)
+	for j := end - 1; j >= i; j-- {
+		if isNewline(runes[j]) {
+			return j + 1
+		}
+	}
+	if end == n || end-i == 1 {
+		return end
+	}
+	// Leave the last space to be joined with the following word
+	return end - 1
+}
+
+func scanWhile(runes []rune, i int, pred func(rune) bool) int {
+	for i < len(runes) && pred(runes[i]) {
+		i++
+	}
+	return i
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
@@ -0,0 +1,291 @@
+package context
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/services/audio"
+	"github.com/shashwatssp/deeprecall/internal/services/stt"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// Recordings are rendered as a short header followed by one line per
+// transcript segment, each stamped with its offset into the recording so
+// answers can cite where something was said:
+//
+//	Recording: launch-memo.m4a
+//	Recorded: Monday, 12 Oct 2026 09:30
+//	[00:00:00] Okay, notes on the launch.
+//	[00:00:07] We're moving it to the 24th.
+var segmentLineRe = regexp.MustCompile(`^\[(\d+):(\d{2}):(\d{2})\] `)
+
+// audioExtensions are the recordings a Transcriber can index
+var audioExtensions = []string{".wav", ".mp3", ".m4a"}
+
+// Transcriber indexes audio recordings through a speech-to-text service
+type Transcriber struct {
+	service    stt.Service
+	sampleRate int
+	window     int    // most samples sent in one Transcribe call, 0 for no limit
+	decoder    string // ffmpeg binary for formats other than WAV
+	run        utils.CommandRunner
+}
+
+// NewTranscriber creates a Transcriber that feeds service audio in the
+// configured capture format
+func NewTranscriber(cfg *config.Config, service stt.Service) *Transcriber {
+	sampleRate := cfg.Audio.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	decoder := cfg.STT.Decoder
+	if decoder == "" {
+		decoder = "ffmpeg"
+	}
+
+	return &Transcriber{
+		service:    service,
+		sampleRate: sampleRate,
+		window:     cfg.STT.MaxDurationSeconds * sampleRate,
+		decoder:    decoder,
+		run:        utils.RunCommand,
+	}
+}
+
+// Check verifies that the decoder for compressed formats is installed when
+// any of extensions needs it
+func (t *Transcriber) Check(extensions []string) error {
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		if ext == ".mp3" || ext == ".m4a" {
+			if _, err := exec.LookPath(t.decoder); err != nil {
+				return fmt.Errorf("%s files need %s to decode: %w", ext, t.decoder, err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// parseAudio transcribes a recording
+func (t *Transcriber) parseAudio(filePath string, doc *models.Document) error {
+	pcm, err := t.decode(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to decode audio: %w", err)
+	}
+	if len(pcm) == 0 {
+		return fmt.Errorf("audio file has no samples")
+	}
+	duration := time.Duration(len(pcm)/2) * time.Second / time.Duration(t.sampleRate)
+
+	result, err := t.transcribe(pcm)
+	if err != nil {
+		return fmt.Errorf("failed to transcribe audio: %w", err)
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "Recording: %s\n", filepath.Base(filePath))
+	fmt.Fprintf(&content, "Recorded: %s\n", doc.FileModTime.Format("Monday, 2 Jan 2006 15:04"))
+	for _, segment := range result.Segments {
+		text := collapseSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(&content, "[%s] %s\n", formatOffset(segment.Start), text)
+	}
+
+	doc.Content = content.String()
+	doc.Metadata["content_format"] = "transcript"
+	doc.Metadata["duration"] = strconv.FormatFloat(duration.Seconds(), 'f', 1, 64)
+	doc.Metadata["recorded"] = doc.FileModTime.Format(time.RFC3339)
+	if result.Language != "" {
+		doc.Metadata["language"] = result.Language
+	}
+
+	return nil
+}
+
+// transcribe sends the recording to the service in windows no longer than
+// stt.max_duration_seconds, cutting each at its quietest moment near the end
+// so words aren't split, and shifts segment times onto the whole recording
+func (t *Transcriber) transcribe(pcm []byte) (*models.TranscriptionResult, error) {
+	samples := len(pcm) / 2
+	merged := &models.TranscriptionResult{}
+	var text []string
+	var weighted float64
+
+	for start := 0; start < samples; {
+		end := samples
+		if t.window > 0 && start+t.window < samples {
+			end = quietestCut(pcm, start+t.window*4/5, start+t.window, t.sampleRate/50)
+		}
+
+		result, err := t.service.Transcribe(pcm[2*start : 2*end])
+		if err != nil {
+			return nil, err
+		}
+
+		offset := time.Duration(start) * time.Second / time.Duration(t.sampleRate)
+		length := time.Duration(end-start) * time.Second / time.Duration(t.sampleRate)
+		segments := result.Segments
+		if len(segments) == 0 {
+			// Providers without timing report the whole window as one segment
+			segments = []models.TranscriptSegment{{End: length, Text: result.Text, Confidence: result.Confidence}}
+		}
+		for _, segment := range segments {
+			segment.Start += offset
+			segment.End += offset
+			merged.Segments = append(merged.Segments, segment)
+		}
+
+		if merged.Language == "" {
+			merged.Language = result.Language
+		}
+		text = append(text, strings.TrimSpace(result.Text))
+		weighted += result.Confidence * float64(end-start)
+		merged.Duration += result.Duration
+		start = end
+	}
+
+	merged.Text = strings.Join(text, " ")
+	if samples > 0 {
+		merged.Confidence = weighted / float64(samples)
+	}
+	return merged, nil
+}
+
+// quietestCut returns the start of the lowest-energy frame of 16-bit PCM
+// between samples from and to
+func quietestCut(pcm []byte, from, to, frame int) int {
+	frame = max(frame, 1)
+	best, bestEnergy := to, -1.0
+	for pos := from; pos+frame <= to; pos += frame {
+		var energy float64
+		for i := pos; i < pos+frame; i++ {
+			s := float64(int16(binary.LittleEndian.Uint16(pcm[2*i:])))
+			energy += s * s
+		}
+		if bestEnergy < 0 || energy < bestEnergy {
+			best, bestEnergy = pos, energy
+		}
+	}
+	return best
+}
+
+// decode returns the recording as mono 16-bit PCM at the service's rate.
+// WAV is read directly; other formats go through ffmpeg.
+func (t *Transcriber) decode(filePath string) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(filePath), ".wav") {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		samples, err := audio.DecodeWAV(data, t.sampleRate)
+		if err != nil {
+			return nil, err
+		}
+		return audio.PCM16(samples), nil
+	}
+
+	out, err := t.run(context.Background(), t.decoder,
+		"-nostdin", "-v", "error", "-i", filePath,
+		"-f", "s16le", "-acodec", "pcm_s16le", "-ac", "1", "-ar", strconv.Itoa(t.sampleRate), "-")
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", t.decoder, err)
+	}
+	return out, nil
+}
+
+// isTranscript reports whether a document should use the transcript chunker
+func isTranscript(doc *models.Document) bool {
+	return doc.Metadata["content_format"] == "transcript"
+}
+
+// chunkTranscript groups whole transcript segments into chunks. Every chunk
+// repeats the recording header and records the time span it covers.
+func (e *Embedder) chunkTranscript(doc *models.Document, text string, size int) []*models.Chunk {
+	type segmentLine struct {
+		start float64
+		text  string
+	}
+
+	var header []string
+	var lines []segmentLine
+	for _, line := range strings.Split(text, "\n") {
+		if m := segmentLineRe.FindStringSubmatch(line); m != nil {
+			hours, _ := strconv.Atoi(m[1])
+			minutes, _ := strconv.Atoi(m[2])
+			seconds, _ := strconv.Atoi(m[3])
+			lines = append(lines, segmentLine{start: float64(hours*3600 + minutes*60 + seconds), text: line})
+		} else if strings.TrimSpace(line) != "" && len(lines) == 0 {
+			header = append(header, line)
+		}
+	}
+
+	duration, _ := strconv.ParseFloat(doc.Metadata["duration"], 64)
+	prefix := strings.Join(header, "\n")
+	budget := max(size-e.tokenizer.Count(prefix), size/2)
+
+	var chunks []*models.Chunk
+	add := func(content string, first, last int) {
+		end := max(duration, lines[last].start)
+		if last+1 < len(lines) {
+			end = lines[last+1].start
+		}
+		chunk := newChunk(doc, prefix+"\n"+content, len(chunks))
+		chunk.Metadata["start_time"] = lines[first].start
+		chunk.Metadata["end_time"] = end
+		chunk.Metadata["timestamp"] = strings.Trim(segmentLineRe.FindString(lines[first].text), "[] ")
+		chunks = append(chunks, chunk)
+	}
+
+	first, tokens := 0, 0
+	var group []string
+	flush := func(last int) {
+		if len(group) > 0 {
+			add(strings.Join(group, "\n"), first, last)
+		}
+		group, tokens = nil, 0
+	}
+
+	for i, line := range lines {
+		lineTokens := e.tokenizer.Count(line.text) + 1
+		if tokens+lineTokens > budget && len(group) > 0 {
+			flush(i - 1)
+		}
+
+		// A single segment over the budget is split, each part keeping its timestamp
+		if lineTokens > budget {
+			label := segmentLineRe.FindString(line.text)
+			for _, window := range e.splitByTokens(strings.TrimPrefix(line.text, label), budget-e.tokenizer.Count(label), 0) {
+				add(label+strings.TrimSpace(window), i, i)
+			}
+			continue
+		}
+
+		if len(group) == 0 {
+			first = i
+		}
+		group = append(group, line.text)
+		tokens += lineTokens
+	}
+	flush(len(lines) - 1)
+
+	return chunks
+}
+
+// formatOffset formats a time offset as hh:mm:ss
+func formatOffset(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
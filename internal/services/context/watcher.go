@@ -1,7 +1,6 @@
 package context
 
 import (
-	"path/filepath"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -67,16 +66,7 @@ func (w *Watcher) watch() {
 
 			// Only handle write and create events
 			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-				ext := filepath.Ext(event.Name)
-				supported := false
-				for _, supportedExt := range w.cfg.Context.SupportedExtensions {
-					if ext == supportedExt {
-						supported = true
-						break
-					}
-				}
-
-				if !supported {
+				if !w.indexer.indexable(event.Name) {
 					continue
 				}
 
@@ -0,0 +1,402 @@
+package context
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// xlsxWorkbook is xl/workbook.xml: the sheet names and their relationship IDs
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name  string `xml:"name,attr"`
+		State string `xml:"state,attr"`
+		RID   string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelationships is a .rels part mapping relationship IDs to part paths
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxStyles is the part of xl/styles.xml needed to tell dates from numbers
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+// Kinds of number format
+const (
+	formatNumber = iota
+	formatDate
+	formatTime
+	formatDateTime
+)
+
+// xlsxWorkbookReader holds the workbook-wide parts needed to read cells
+type xlsxWorkbookReader struct {
+	strings  []string
+	formats  []int // number format kind by cell style index
+	date1904 bool
+}
+
+// parseXLSX reads every visible sheet of an Excel workbook
+func parseXLSX(filePath string, doc *models.Document) error {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open XLSX: %w", err)
+	}
+	defer zr.Close()
+
+	data, err := readZipEntry(&zr.Reader, "xl/workbook.xml")
+	if err != nil {
+		return fmt.Errorf("failed to read XLSX workbook: %w", err)
+	}
+	var workbook xlsxWorkbook
+	if err := xml.Unmarshal(data, &workbook); err != nil {
+		return fmt.Errorf("failed to parse XLSX workbook: %w", err)
+	}
+
+	targets := make(map[string]string)
+	if data, err := readZipEntry(&zr.Reader, "xl/_rels/workbook.xml.rels"); err == nil {
+		var rels xlsxRelationships
+		if err := xml.Unmarshal(data, &rels); err == nil {
+			for _, rel := range rels.Relationships {
+				targets[rel.ID] = rel.Target
+			}
+		}
+	}
+
+	wr := &xlsxWorkbookReader{date1904: workbook.Properties.Date1904 == "1" || workbook.Properties.Date1904 == "true"}
+	if data, err := readZipEntry(&zr.Reader, "xl/sharedStrings.xml"); err == nil {
+		wr.strings = xlsxSharedStrings(data)
+	}
+	if data, err := readZipEntry(&zr.Reader, "xl/styles.xml"); err == nil {
+		wr.formats = xlsxFormats(data)
+	}
+
+	var content strings.Builder
+	var names []string
+	total := 0
+
+	for i, sheet := range workbook.Sheets {
+		if sheet.State == "hidden" || sheet.State == "veryHidden" {
+			continue
+		}
+
+		target, ok := targets[sheet.RID]
+		if !ok {
+			// Workbooks without relationships use the conventional part names
+			target = fmt.Sprintf("worksheets/sheet%d.xml", i+1)
+		}
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+
+		data, err := readZipEntry(&zr.Reader, target)
+		if err != nil {
+			utils.GetLogger().Warnf("Failed to read sheet %q: %v", sheet.Name, err)
+			continue
+		}
+
+		rows, err := wr.rows(data)
+		if err != nil {
+			utils.GetLogger().Warnf("Failed to parse sheet %q: %v", sheet.Name, err)
+			continue
+		}
+
+		total += writeSheet(&content, sheet.Name, rows)
+		names = append(names, sheet.Name)
+	}
+
+	doc.Content = content.String()
+	doc.Metadata["content_format"] = "rows"
+	doc.Metadata["sheets"] = strings.Join(names, ", ")
+	doc.Metadata["rows"] = strconv.Itoa(total)
+
+	if core, err := readZipEntry(&zr.Reader, "docProps/core.xml"); err == nil {
+		setMetadata(doc.Metadata, coreProperties(core))
+	}
+
+	return nil
+}
+
+// xlsxSharedStrings reads the shared string table, dropping phonetic hints
+func xlsxSharedStrings(data []byte) []string {
+	var result []string
+	var current strings.Builder
+	inText, inPhonetic := false, false
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return result
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.CharData:
+			if inText && !inPhonetic {
+				current.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				result = append(result, current.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		}
+	}
+}
+
+// xlsxFormats returns the number format kind of each cell style
+func xlsxFormats(data []byte) []int {
+	var styles xlsxStyles
+	if err := xml.Unmarshal(data, &styles); err != nil {
+		return nil
+	}
+
+	custom := make(map[int]string)
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+
+	formats := make([]int, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		if code, ok := custom[xf.NumFmtID]; ok {
+			formats[i] = numberFormatKind(code)
+			continue
+		}
+		// Built-in formats (ECMA-376 18.8.30)
+		switch id := xf.NumFmtID; {
+		case id >= 14 && id <= 17:
+			formats[i] = formatDate
+		case id == 22:
+			formats[i] = formatDateTime
+		case id >= 18 && id <= 21, id >= 45 && id <= 47:
+			formats[i] = formatTime
+		}
+	}
+	return formats
+}
+
+// numberFormatKind classifies a custom number format code
+func numberFormatKind(code string) int {
+	var b strings.Builder
+	inQuote, inBracket := false, false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '[':
+			inBracket = true
+		case c == ']':
+			inBracket = false
+		case inBracket:
+		case c == '\\' || c == '_' || c == '*':
+			i++ // escaped or padding character
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	// Only the first section applies to positive numbers
+	plain, _, _ := strings.Cut(strings.ToLower(b.String()), ";")
+	hasDate := strings.ContainsAny(plain, "dy")
+	hasTime := strings.ContainsAny(plain, "hs")
+
+	switch {
+	case hasDate && hasTime:
+		return formatDateTime
+	case hasDate, strings.Contains(plain, "m") && !hasTime:
+		return formatDate
+	case hasTime:
+		return formatTime
+	}
+	return formatNumber
+}
+
+// rows reads the cells of a worksheet part
+func (wr *xlsxWorkbookReader) rows(data []byte) ([]sheetRow, error) {
+	var rows []sheetRow
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var (
+		row                 *sheetRow
+		ref, typ, style     string
+		value               strings.Builder
+		inValue, inPhonetic bool
+		nextColumn          int
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				number, err := strconv.Atoi(xmlAttr(t, "r"))
+				if err != nil {
+					number = len(rows) + 1
+				}
+				rows = append(rows, sheetRow{number: number})
+				row = &rows[len(rows)-1]
+				nextColumn = 0
+			case "c":
+				ref, typ, style = xmlAttr(t, "r"), xmlAttr(t, "t"), xmlAttr(t, "s")
+				value.Reset()
+			case "v", "t":
+				inValue = true
+			case "rPh":
+				inPhonetic = true
+			}
+
+		case xml.CharData:
+			if inValue && !inPhonetic {
+				value.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "rPh":
+				inPhonetic = false
+			case "c":
+				if row == nil {
+					continue
+				}
+				column := cellColumn(ref)
+				if column < 0 {
+					column = nextColumn
+				}
+				nextColumn = column + 1
+
+				for len(row.cells) <= column {
+					row.cells = append(row.cells, "")
+				}
+				row.cells[column] = wr.cellValue(value.String(), typ, style)
+			case "row":
+				row = nil
+			}
+		}
+	}
+}
+
+// cellValue converts a raw cell value to display text
+func (wr *xlsxWorkbookReader) cellValue(raw, typ, style string) string {
+	switch typ {
+	case "s":
+		if i, err := strconv.Atoi(raw); err == nil && i >= 0 && i < len(wr.strings) {
+			return wr.strings[i]
+		}
+		return ""
+	case "b":
+		if raw == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "inlineStr", "str", "e", "d":
+		return raw
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return raw
+	}
+
+	kind := formatNumber
+	if i, err := strconv.Atoi(style); err == nil && i >= 0 && i < len(wr.formats) {
+		kind = wr.formats[i]
+	}
+	if kind != formatNumber {
+		return excelDate(f, wr.date1904, kind)
+	}
+
+	// Round away binary noise (0.30000000000000004) to Excel's 15 digits
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// excelDate formats a serial date number
+func excelDate(serial float64, date1904 bool, kind int) string {
+	// The 1900 system counts from 1899-12-30 because of Lotus 1-2-3's leap year bug
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	t := epoch.Add(time.Duration(math.Round(serial*86400)) * time.Second)
+	switch kind {
+	case formatTime:
+		return t.Format("15:04:05")
+	case formatDateTime:
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return t.Format("2006-01-02")
+}
+
+// maxXLSXColumns is the number of columns in a worksheet (A to XFD)
+const maxXLSXColumns = 16384
+
+// cellColumn returns the 0-based column of a cell reference like "AB12",
+// or -1 if there is none or it is past the last worksheet column
+func cellColumn(ref string) int {
+	column := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		column = column*26 + int(ref[i]-'A'+1)
+		if column > maxXLSXColumns {
+			return -1
+		}
+	}
+	if i == 0 {
+		return -1
+	}
+	return column - 1
+}
@@ -0,0 +1,351 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	contextpkg "github.com/shashwatssp/deeprecall/internal/services/context"
+	"github.com/shashwatssp/deeprecall/internal/services/llm"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultHistoryTokens  = 1500
+	defaultKeepTurns      = 3
+	defaultSessionTimeout = 30 * time.Minute
+	defaultRetention      = 30 * 24 * time.Hour
+	summaryMaxTokens      = 300
+)
+
+var conversationsBucket = []byte("conversations")
+
+// summarizePrompt asks the LLM to fold older turns into the running summary
+const summarizePrompt = `Summarize this conversation between a user and an assistant in a few sentences.
+Keep names, numbers, lists and anything the user might refer back to, in the order they came up.`
+
+// conversation is the history of one conversation. Messages holds the
+// user and assistant turns not yet folded into Summary.
+type conversation struct {
+	ID       string
+	Started  time.Time
+	Updated  time.Time
+	Summary  string
+	Messages []models.Message
+
+	summarizing bool // an LLM call is folding older messages into Summary
+}
+
+// conversationHistory remembers earlier turns so follow-up questions have
+// something to refer to. Each question starts or continues a conversation;
+// one idle for longer than the session timeout is closed and the next
+// question starts a new one.
+type conversationHistory struct {
+	tokenizer contextpkg.Tokenizer
+	summarize func(summary string, messages []models.Message) (string, error)
+	db        *bolt.DB // nil when history isn't persisted
+	budget    int
+	keep      int
+	timeout   time.Duration
+	retention time.Duration
+
+	mu         sync.Mutex
+	current    *conversation
+	compacting sync.WaitGroup // summaries still being written
+}
+
+// openConversationHistory opens the history database, resuming the latest
+// conversation if it hasn't timed out
+func openConversationHistory(cfg *config.Config, tokenizer contextpkg.Tokenizer, summarize func(string, []models.Message) (string, error)) (*conversationHistory, error) {
+	convCfg := cfg.Conversation
+
+	h := &conversationHistory{
+		tokenizer: tokenizer,
+		summarize: summarize,
+		budget:    convCfg.HistoryTokens,
+		keep:      convCfg.KeepTurns,
+		timeout:   time.Duration(convCfg.SessionTimeoutMinutes) * time.Minute,
+		retention: time.Duration(convCfg.HistoryRetentionDays) * 24 * time.Hour,
+	}
+	if h.budget <= 0 {
+		h.budget = defaultHistoryTokens
+	}
+	if h.keep <= 0 {
+		h.keep = defaultKeepTurns
+	}
+	if h.timeout <= 0 {
+		h.timeout = defaultSessionTimeout
+	}
+	if h.retention <= 0 {
+		h.retention = defaultRetention
+	}
+
+	if convCfg.HistoryPath == "" {
+		return h, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(convCfg.HistoryPath), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(convCfg.HistoryPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open conversation history: %w", err)
+	}
+
+	// Conversation IDs sort by start time, so the last key is the latest
+	var latest *conversation
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(conversationsBucket)
+		if err != nil {
+			return err
+		}
+		if _, data := bucket.Cursor().Last(); data != nil {
+			latest = &conversation{}
+			return gob.NewDecoder(bytes.NewReader(data)).Decode(latest)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read conversation history: %w", err)
+	}
+
+	h.db = db
+	if err := h.prune(time.Now()); err != nil {
+		utils.GetLogger().Warnf("Failed to delete old conversations: %v", err)
+	}
+	if latest != nil && time.Since(latest.Updated) < h.timeout {
+		h.current = latest
+		utils.GetLogger().Infof("Resuming conversation from %s (%d messages)", latest.Started.Format(time.Kitchen), len(latest.Messages))
+	}
+	return h, nil
+}
+
+// window returns the summary of older turns and the most recent messages
+// that fit the token budget, for a question asked at now
+func (h *conversationHistory) window(now time.Time) (string, []models.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conv := h.active(now)
+	if conv == nil {
+		return "", nil
+	}
+
+	budget := h.budget - h.tokenizer.Count(conv.Summary)
+	start := len(conv.Messages)
+	for start > 0 {
+		cost := h.tokenizer.Count(conv.Messages[start-1].Content)
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		start--
+	}
+	// Don't open with an answer whose question was cut
+	for start < len(conv.Messages) && conv.Messages[start].Role != "user" {
+		start++
+	}
+
+	messages := append([]models.Message(nil), conv.Messages[start:]...)
+	return conv.Summary, messages
+}
+
+// record adds a question and its answer to the current conversation and
+// saves it. Once older turns outgrow the budget they are summarized in the
+// background, so the answer isn't held up by another LLM call.
+func (h *conversationHistory) record(question, answer string, now time.Time) error {
+	h.mu.Lock()
+	conv := h.active(now)
+	started := conv == nil
+	if started {
+		conv = &conversation{ID: now.UTC().Format("20060102T150405.000000000"), Started: now}
+		h.current = conv
+	}
+	conv.Updated = now
+	conv.Messages = append(conv.Messages,
+		models.Message{Role: "user", Content: question},
+		models.Message{Role: "assistant", Content: answer},
+	)
+
+	summary, older := h.overflow(conv)
+	err := h.save(conv)
+	h.mu.Unlock()
+
+	// A new conversation is a good time to forget ones long over
+	if started {
+		if err := h.prune(now); err != nil {
+			utils.GetLogger().Warnf("Failed to delete old conversations: %v", err)
+		}
+	}
+
+	if older != nil {
+		h.compacting.Add(1)
+		go func() {
+			defer h.compacting.Done()
+			if err := h.compact(conv, summary, older); err != nil {
+				utils.GetLogger().Warnf("Failed to save conversation summary: %v", err)
+			}
+		}()
+	}
+	return err
+}
+
+// end closes the current conversation, so the next question starts afresh
+func (h *conversationHistory) end() {
+	h.mu.Lock()
+	h.current = nil
+	h.mu.Unlock()
+}
+
+// active returns the current conversation, or nil if it has timed out
+func (h *conversationHistory) active(now time.Time) *conversation {
+	if h.current != nil && now.Sub(h.current.Updated) >= h.timeout {
+		h.current = nil
+	}
+	return h.current
+}
+
+// overflow returns the summary and the older messages to fold into it when
+// the conversation no longer fits the budget, marking it as summarizing.
+// It returns nil messages if there is nothing to do. h.mu must be held.
+func (h *conversationHistory) overflow(conv *conversation) (string, []models.Message) {
+	if conv.summarizing {
+		return "", nil
+	}
+
+	tokens := h.tokenizer.Count(conv.Summary)
+	for _, msg := range conv.Messages {
+		tokens += h.tokenizer.Count(msg.Content)
+	}
+	keep := 2 * h.keep
+	if tokens <= h.budget || len(conv.Messages) <= keep {
+		return "", nil
+	}
+
+	conv.summarizing = true
+	older := append([]models.Message(nil), conv.Messages[:len(conv.Messages)-keep]...)
+	return conv.Summary, older
+}
+
+// compact folds older, the first messages of conv, into summary, swaps the
+// result in and saves it. Messages recorded meanwhile stay, as they come
+// after older. If summarizing fails the older turns are dropped instead.
+func (h *conversationHistory) compact(conv *conversation, summary string, older []models.Message) error {
+	folded, err := h.summarize(summary, older)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conv.summarizing = false
+	if err != nil {
+		utils.GetLogger().Warnf("Failed to summarize conversation, dropping %d older messages: %v", len(older), err)
+	} else {
+		conv.Summary = folded
+	}
+	conv.Messages = append([]models.Message(nil), conv.Messages[len(older):]...)
+
+	return h.save(conv)
+}
+
+// save writes a conversation to the database, if there is one. h.mu must be held.
+func (h *conversationHistory) save(conv *conversation) error {
+	if h.db == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(conv); err != nil {
+		return fmt.Errorf("failed to encode conversation: %w", err)
+	}
+	return h.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(conversationsBucket).Put([]byte(conv.ID), buf.Bytes())
+	})
+}
+
+// prune deletes saved conversations idle for longer than the retention period
+func (h *conversationHistory) prune(now time.Time) error {
+	if h.db == nil {
+		return nil
+	}
+
+	var deleted int
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(conversationsBucket)
+		var stale [][]byte
+		err := bucket.ForEach(func(key, data []byte) error {
+			var conv conversation
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&conv); err != nil {
+				return err
+			}
+			if now.Sub(conv.Updated) > h.retention {
+				stale = append(stale, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range stale {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		deleted = len(stale)
+		return nil
+	})
+
+	if deleted > 0 {
+		utils.GetLogger().Infof("Deleted %d conversations idle for over %v", deleted, h.retention)
+	}
+	return err
+}
+
+// Close waits for summaries in progress and closes the history database
+func (h *conversationHistory) Close() error {
+	h.compacting.Wait()
+	if h.db == nil {
+		return nil
+	}
+	return h.db.Close()
+}
+
+// llmSummarizer returns a summarize function backed by the LLM
+func llmSummarizer(client *llm.OpenAIClient) func(string, []models.Message) (string, error) {
+	return func(summary string, messages []models.Message) (string, error) {
+		resp, err := client.Generate(&models.LLMRequest{
+			Messages:    summarizeMessages(summary, messages),
+			MaxTokens:   summaryMaxTokens,
+			Temperature: 0,
+		})
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(resp.Content), nil
+	}
+}
+
+// summarizeMessages builds the request that folds messages into summary
+func summarizeMessages(summary string, messages []models.Message) []models.Message {
+	var transcript strings.Builder
+	if summary != "" {
+		transcript.WriteString("Earlier: " + summary + "\n\n")
+	}
+	for _, msg := range messages {
+		transcript.WriteString(msg.Role + ": " + msg.Content + "\n")
+	}
+
+	return []models.Message{
+		{Role: "system", Content: summarizePrompt},
+		{Role: "user", Content: transcript.String()},
+	}
+}
@@ -0,0 +1,159 @@
+package orchestrator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// wordTokenizer counts one token per word
+type wordTokenizer struct{}
+
+func (wordTokenizer) Encode(text string) []int   { return make([]int, len(strings.Fields(text))) }
+func (wordTokenizer) Decode(tokens []int) string { return "" }
+func (wordTokenizer) Count(text string) int      { return len(strings.Fields(text)) }
+
+func newTestHistory(t *testing.T, budget, keep int, summarize func(string, []models.Message) (string, error)) *conversationHistory {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Conversation.HistoryTokens = budget
+	cfg.Conversation.KeepTurns = keep
+	h, err := openConversationHistory(cfg, wordTokenizer{}, summarize)
+	if err != nil {
+		t.Fatalf("openConversationHistory: %v", err)
+	}
+	return h
+}
+
+func contents(messages []models.Message) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestHistoryWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	messages := []models.Message{
+		{Role: "user", Content: "one two three"},
+		{Role: "assistant", Content: "four five six"},
+		{Role: "user", Content: "seven eight"},
+		{Role: "assistant", Content: "nine ten"},
+	}
+
+	tests := []struct {
+		name    string
+		budget  int
+		summary string
+		at      time.Time
+		want    []string
+	}{
+		{"everything fits", 10, "", now, []string{"one two three", "four five six", "seven eight", "nine ten"}},
+		{"summary counts against the budget", 10, "earlier turns", now, []string{"seven eight", "nine ten"}},
+		// "four five six" fits but its question doesn't, so it is left out too
+		{"no answer without its question", 7, "", now, []string{"seven eight", "nine ten"}},
+		{"nothing fits", 1, "", now, nil},
+		{"timed out", 10, "", now.Add(defaultSessionTimeout), nil},
+	}
+
+	for _, tt := range tests {
+		h := newTestHistory(t, tt.budget, 1, nil)
+		h.current = &conversation{Updated: now, Summary: tt.summary, Messages: messages}
+
+		summary, window := h.window(tt.at)
+		if got := contents(window); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: window = %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.at.Equal(now) && summary != tt.summary {
+			t.Errorf("%s: summary = %q, want %q", tt.name, summary, tt.summary)
+		}
+	}
+}
+
+// Turns recorded while older ones are being summarized are kept, and no
+// second summary starts until the first is in
+func TestHistoryCompactInterleaving(t *testing.T) {
+	type call struct {
+		summary  string
+		messages []string
+	}
+	calls := make(chan call, 2)
+	results := make(chan error)
+	summarize := func(summary string, messages []models.Message) (string, error) {
+		calls <- call{summary, contents(messages)}
+		if err := <-results; err != nil {
+			return "", err
+		}
+		return "summary", nil
+	}
+
+	// Each turn is 8 tokens; two of them overflow a budget of 10
+	h := newTestHistory(t, 10, 1, summarize)
+	now := time.Now()
+	record := func(n string) {
+		t.Helper()
+		if err := h.record("question "+n+" two three", "answer "+n+" two three", now); err != nil {
+			t.Fatalf("record %s: %v", n, err)
+		}
+	}
+
+	record("1")
+	record("2") // starts summarizing turn 1; record must not wait for it
+
+	select {
+	case got := <-calls:
+		if want := (call{"", []string{"question 1 two three", "answer 1 two three"}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("summarize(%q, %q), want (%q, %q)", got.summary, got.messages, want.summary, want.messages)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("summarize not called")
+	}
+
+	record("3") // overflows again, but a summary is already in progress
+	select {
+	case got := <-calls:
+		t.Errorf("second summarize started while the first was running: %v", got)
+	default:
+	}
+
+	results <- nil
+	h.compacting.Wait()
+
+	summary, window := h.window(now)
+	if summary != "summary" {
+		t.Errorf("summary = %q, want %q", summary, "summary")
+	}
+	// Turn 2 no longer fits beside turn 3 and the summary
+	if want := []string{"question 3 two three", "answer 3 two three"}; !reflect.DeepEqual(contents(window), want) {
+		t.Errorf("window = %q, want %q", contents(window), want)
+	}
+	if got := len(h.current.Messages); got != 4 {
+		t.Errorf("%d messages kept after compacting, want turns 2 and 3", got)
+	}
+
+	// The next overflow folds turns 2 and 3 into the summary; if that fails
+	// they are dropped and the summary stays
+	record("4")
+	got := <-calls
+	if want := (call{"summary", []string{"question 2 two three", "answer 2 two three", "question 3 two three", "answer 3 two three"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("summarize(%q, %q), want (%q, %q)", got.summary, got.messages, want.summary, want.messages)
+	}
+	results <- errors.New("LLM unavailable")
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if h.current.Summary != "summary" || !reflect.DeepEqual(contents(h.current.Messages), []string{"question 4 two three", "answer 4 two three"}) {
+		t.Errorf("after failed summary: summary %q, messages %q", h.current.Summary, contents(h.current.Messages))
+	}
+	if h.current.summarizing {
+		t.Errorf("still marked as summarizing")
+	}
+}
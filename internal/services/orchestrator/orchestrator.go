@@ -1,15 +1,18 @@
 package orchestrator
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/shashwatssp/deeprecall/internal/config"
 	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/services/audio"
 	contextpkg "github.com/shashwatssp/deeprecall/internal/services/context"
 	"github.com/shashwatssp/deeprecall/internal/services/llm"
 	"github.com/shashwatssp/deeprecall/internal/services/retriever"
+	"github.com/shashwatssp/deeprecall/internal/services/stt"
 	"github.com/shashwatssp/deeprecall/internal/utils"
 )
 
@@ -19,12 +22,35 @@ type Orchestrator struct {
 	watcher   *contextpkg.Watcher
 	retriever *retriever.Retriever
 	llmClient *llm.OpenAIClient
+	stt       stt.Service            // nil when the STT provider failed to start
+	spotter   *audio.WakeWordSpotter // nil unless acoustic wake word spotting is on
+	wakeWords *wakeWordMatcher
+	stopWords *wakeWordMatcher
+	session   *session
+	history   *conversationHistory
+	rewriter  *queryRewriter
 	ready     bool
 }
 
+// wakeWordGraceWords is how many words past the wake word a live caption may
+// run before a missing wake word ends the utterance early, since the first
+// words of a partial hypothesis are often revised
+const wakeWordGraceWords = 2
+
 func NewOrchestrator(cfg *config.Config) (*Orchestrator, error) {
 	// Initialize services
 	indexer := contextpkg.NewIndexer(cfg)
+	sttService, err := stt.NewService(cfg)
+	if err != nil {
+		utils.GetLogger().Warnf("Speech-to-text unavailable, audio files won't be indexed: %v", err)
+		sttService = nil
+	} else {
+		indexer.EnableTranscription(sttService)
+	}
+	if err := indexer.CheckFormats(); err != nil {
+		return nil, err
+	}
+
 	retriever, err := retriever.NewRetriever(cfg)
 	if err != nil {
 		return nil, err
@@ -35,14 +61,34 @@ func NewOrchestrator(cfg *config.Config) (*Orchestrator, error) {
 		return nil, err
 	}
 
+	var spotter *audio.WakeWordSpotter
+	if cfg.WakeWord.Enabled && cfg.WakeWord.Acoustic.Enabled {
+		spotter, err = audio.NewWakeWordSpotter(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up acoustic wake word: %w", err)
+		}
+	}
+
 	llmClient := llm.NewOpenAIClient(cfg)
 
+	history, err := openConversationHistory(cfg, contextpkg.NewTokenizer(cfg), llmSummarizer(llmClient))
+	if err != nil {
+		return nil, err
+	}
+
 	orch := &Orchestrator{
 		cfg:       cfg,
 		indexer:   indexer,
 		watcher:   watcher,
 		retriever: retriever,
 		llmClient: llmClient,
+		stt:       sttService,
+		spotter:   spotter,
+		wakeWords: newWakeWordMatcher(cfg.WakeWord),
+		stopWords: newStopPhraseMatcher(cfg.Conversation.StopPhrases, cfg.WakeWord),
+		session:   newSession(cfg),
+		history:   history,
+		rewriter:  newQueryRewriter(cfg, llmClient),
 	}
 
 	// Set up watcher callback
@@ -58,37 +104,88 @@ func (o *Orchestrator) Start() error {
 	logger := utils.GetLogger()
 	logger.Info("Starting DeepRecall Orchestrator...")
 
-	// Initial indexing
+	if o.retriever.NeedsMigration() {
+		// Rebuild in the background; queries use the old index until it's
+		// swapped. The new index exists before the watcher starts, so file
+		// changes from here on are indexed into it.
+		logger.Warn("Embedding configuration changed, re-indexing context in the background...")
+		if err := o.retriever.BeginMigration(); err != nil {
+			return err
+		}
+		go o.migrateIndex()
+	} else if err := o.indexContext(); err != nil {
+		return err
+	}
+
+	// Start file watcher
+	if err := o.watcher.Start(); err != nil {
+		return fmt.Errorf("failed to start watcher: %w", err)
+	}
+
+	o.ready = true
+	logger.Info("DeepRecall Orchestrator is ready!")
+	return nil
+}
+
+// indexContext indexes the context folder into the retriever
+func (o *Orchestrator) indexContext() error {
+	logger := utils.GetLogger()
+
 	logger.Info("Performing initial context indexing...")
-	results, err := o.indexer.IndexDirectory(o.cfg.Context.Folder)
+	totalFiles, totalChunks, err := o.indexFolder(o.retriever.IndexChunks)
 	if err != nil {
 		return fmt.Errorf("failed to index context directory: %w", err)
 	}
 
+	logger.Infof("Indexed %d files with %d total chunks", totalFiles, totalChunks)
+	return nil
+}
+
+// migrateIndex rebuilds the vector store with the current embedding configuration
+func (o *Orchestrator) migrateIndex() {
+	logger := utils.GetLogger()
+
+	err := o.retriever.Migrate(func(add func([]*models.Chunk) error) error {
+		_, _, err := o.indexFolder(add)
+		return err
+	})
+	if err != nil {
+		logger.Errorf("Index migration failed: %v", err)
+	}
+}
+
+// indexFolder indexes every supported file in the context folder and passes
+// the chunks to add
+func (o *Orchestrator) indexFolder(add func([]*models.Chunk) error) (int, int, error) {
+	logger := utils.GetLogger()
+
+	results, err := o.indexer.IndexDirectory(o.cfg.Context.Folder)
+	if err != nil {
+		return 0, 0, err
+	}
+
 	// Add all chunks to retriever
 	totalChunks := 0
 	for _, chunks := range results {
-		if err := o.retriever.IndexChunks(chunks); err != nil {
+		if err := add(chunks); err != nil {
 			logger.Warnf("Failed to index chunks: %v", err)
 			continue
 		}
 		totalChunks += len(chunks)
 	}
 
-	logger.Infof("Indexed %d files with %d total chunks", len(results), totalChunks)
-
-	// Start file watcher
-	if err := o.watcher.Start(); err != nil {
-		return fmt.Errorf("failed to start watcher: %w", err)
-	}
-
-	o.ready = true
-	logger.Info("DeepRecall Orchestrator is ready!")
-	return nil
+	return len(results), totalChunks, nil
 }
 
 // ProcessVoiceQuery processes a complete voice interaction
 func (o *Orchestrator) ProcessVoiceQuery(transcription string) (*models.VoiceResponse, error) {
+	return o.processTranscript(transcription, "", time.Now())
+}
+
+// processTranscript answers a transcript in the given language, which
+// selects the wake phrases to look for ("" if unknown). Speech that started
+// at heard within the follow-up window needs no wake word.
+func (o *Orchestrator) processTranscript(transcription, language string, heard time.Time) (*models.VoiceResponse, error) {
 	startTime := time.Now()
 	logger := utils.GetLogger()
 
@@ -96,18 +193,41 @@ func (o *Orchestrator) ProcessVoiceQuery(transcription string) (*models.VoiceRes
 		return nil, fmt.Errorf("orchestrator not ready")
 	}
 
-	// Check wake word
-	if !o.matchesWakeWord(transcription) {
+	// Check wake word, unless this is a follow-up
+	followUp := o.session.accepts(heard)
+	match, found := o.wakeWords.find(transcription, language)
+	if !found && !followUp {
 		logger.Debug("Wake word not detected, ignoring")
 		return nil, fmt.Errorf("wake word not detected")
 	}
 
 	// Remove wake word from query
-	query := o.removeWakeWord(transcription)
-	logger.Infof("Processing query: %s", query)
+	query := transcription
+	if found {
+		query = o.wakeWords.remove(transcription, match)
+	}
+
+	if _, stop := o.stopWords.find(query, ""); stop {
+		o.session.end()
+		o.history.end()
+		logger.Info("Conversation ended")
+		return nil, fmt.Errorf("conversation ended")
+	}
+	if !found && len(o.wakeWords.words(query)) == 0 {
+		return nil, fmt.Errorf("nothing said")
+	}
+
+	if followUp {
+		logger.Infof("Processing follow-up: %s", query)
+	} else {
+		logger.Infof("Processing query: %s", query)
+	}
 
-	// Retrieve relevant context
-	results, err := o.retriever.Retrieve(query)
+	// Retrieve relevant context, searching for what the question means in
+	// the conversation rather than its literal words
+	summary, history := o.history.window(heard)
+	queries := o.rewriter.rewrite(query, summary, history)
+	results, err := o.retriever.RetrieveAll(queries)
 	if err != nil {
 		logger.Errorf("Retrieval failed: %v", err)
 		// Continue without context
@@ -117,8 +237,8 @@ func (o *Orchestrator) ProcessVoiceQuery(transcription string) (*models.VoiceRes
 	// Build context string
 	contextStr := o.buildContext(results)
 
-	// Generate LLM response
-	messages := o.buildMessages(query, contextStr)
+	// Generate LLM response, with the conversation so far
+	messages := o.buildMessages(query, contextStr, summary, history)
 	llmReq := &models.LLMRequest{
 		Messages:    messages,
 		MaxTokens:   o.cfg.LLM.MaxTokens,
@@ -131,6 +251,10 @@ func (o *Orchestrator) ProcessVoiceQuery(transcription string) (*models.VoiceRes
 	}
 
 	logger.Infof("Generated response in %v using %d tokens", response.ResponseTime, response.TokensUsed)
+	o.session.open(time.Now())
+	if err := o.history.record(query, response.Content, time.Now()); err != nil {
+		logger.Warnf("Failed to save conversation: %v", err)
+	}
 
 	return &models.VoiceResponse{
 		Text:           response.Content,
@@ -138,46 +262,160 @@ func (o *Orchestrator) ProcessVoiceQuery(transcription string) (*models.VoiceRes
 	}, nil
 }
 
-func (o *Orchestrator) matchesWakeWord(text string) bool {
-	wakeWord := o.cfg.WakeWord.Word
+// ProcessUtterance transcribes a complete utterance from the audio
+// service's voice activity detection and answers it. With acoustic wake word
+// spotting on, utterances that don't sound like the wake word are dropped
+// before transcription.
+func (o *Orchestrator) ProcessUtterance(utterance *models.Utterance) (*models.VoiceResponse, error) {
+	if o.stt == nil {
+		return nil, fmt.Errorf("speech-to-text is not available")
+	}
 
-	if !o.cfg.WakeWord.CaseSensitive {
-		text = strings.ToLower(text)
-		wakeWord = strings.ToLower(wakeWord)
+	start := utterance.Start
+	if start.IsZero() {
+		start = time.Now()
 	}
 
-	text = strings.TrimSpace(text)
+	if o.spotter != nil && !o.session.accepts(start) {
+		if heard, distance := o.spotter.Detect(utterance.Data); !heard {
+			utils.GetLogger().Debugf("Wake word not heard (distance %.2f), skipping transcription", distance)
+			return nil, fmt.Errorf("wake word not detected")
+		}
+	}
 
-	switch o.cfg.WakeWord.MatchType {
-	case "prefix":
-		return strings.HasPrefix(text, wakeWord)
-	case "exact":
-		return text == wakeWord
-	case "contains":
-		return strings.Contains(text, wakeWord)
-	default:
-		return strings.HasPrefix(text, wakeWord)
+	result, err := o.stt.Transcribe(utterance.Data)
+	if err != nil {
+		return nil, fmt.Errorf("transcription failed: %w", err)
 	}
+	utils.GetLogger().Debugf("Transcribed %v utterance: %q", utterance.Duration, result.Text)
+
+	return o.processTranscript(result.Text, result.Language, start)
 }
 
-func (o *Orchestrator) removeWakeWord(text string) string {
-	wakeWord := o.cfg.WakeWord.Word
+// ProcessVoiceStream transcribes audio as it is recorded, passing live
+// captions to onCaption (which may be nil), and answers once the stream ends.
+// An utterance that has clearly not started with the wake word is abandoned
+// without waiting for the speaker to finish.
+func (o *Orchestrator) ProcessVoiceStream(ctx context.Context, audio <-chan []byte, onCaption func(text string, final bool)) (*models.VoiceResponse, error) {
+	logger := utils.GetLogger()
+
+	if o.stt == nil {
+		return nil, fmt.Errorf("speech-to-text is not available")
+	}
 
-	if !o.cfg.WakeWord.CaseSensitive {
-		// Case-insensitive removal
-		lower := strings.ToLower(text)
-		lowerWake := strings.ToLower(wakeWord)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		if strings.HasPrefix(lower, lowerWake) {
-			return strings.TrimSpace(text[len(wakeWord):])
+	// A follow-up needs no wake word, so there's nothing to screen for
+	heard := time.Now()
+	followUp := o.session.accepts(heard)
+
+	if o.spotter != nil && !followUp {
+		gated, err := o.gateStream(ctx, audio)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		if strings.HasPrefix(text, wakeWord) {
-			return strings.TrimSpace(text[len(wakeWord):])
+		audio = gated
+	}
+
+	detected := followUp
+	for update := range o.stt.TranscribeLive(ctx, audio) {
+		if update.Err != nil {
+			return nil, fmt.Errorf("transcription failed: %w", update.Err)
+		}
+
+		text := update.Result.Text
+		if onCaption != nil {
+			onCaption(text, update.Final)
+		}
+
+		if update.Final {
+			return o.processTranscript(text, update.Result.Language, heard)
+		}
+
+		if detected {
+			continue
+		}
+		if _, found := o.wakeWords.find(text, update.Result.Language); found {
+			detected = true
+			logger.Debugf("Wake word detected in live caption: %q", text)
+		} else if o.wakeWords.ruledOut(text, update.Result.Language, wakeWordGraceWords) {
+			logger.Debug("Wake word not detected, ignoring")
+			return nil, fmt.Errorf("wake word not detected")
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("transcription ended without a result")
+}
+
+// gateStream holds back the start of a stream until the wake word spotter
+// has heard enough to decide, then replays it followed by the rest of the
+// stream. When the wake word may come anywhere, the spotter checks the whole
+// stream and transcription only starts once it ends.
+func (o *Orchestrator) gateStream(ctx context.Context, stream <-chan []byte) (<-chan []byte, error) {
+	window := o.spotter.Window()
+
+	var head []byte
+	open := true
+	for open && (window == 0 || len(head) < window) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case chunk, ok := <-stream:
+			open = ok
+			head = append(head, chunk...)
 		}
 	}
 
-	return text
+	if heard, distance := o.spotter.Detect(head); !heard {
+		utils.GetLogger().Debugf("Wake word not heard (distance %.2f), skipping transcription", distance)
+		return nil, fmt.Errorf("wake word not detected")
+	}
+
+	gated := make(chan []byte, 1)
+	gated <- head
+	go func() {
+		defer close(gated)
+		for open {
+			select {
+			case <-ctx.Done():
+				return
+			case chunk, ok := <-stream:
+				if open = ok; !ok {
+					return
+				}
+				select {
+				case gated <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return gated, nil
+}
+
+// InConversation reports whether the next utterance would be taken as a
+// follow-up without the wake word
+func (o *Orchestrator) InConversation() bool {
+	return o.session.accepts(time.Now())
+}
+
+// ListenForFollowUp restarts the follow-up window after the last answer.
+// Callers that speak the answer aloud use it once playback ends, so the
+// window isn't spent while the answer is still playing.
+func (o *Orchestrator) ListenForFollowUp() {
+	o.session.resume(time.Now())
+}
+
+// EndConversation closes the follow-up window, so the next utterance needs
+// the wake word again
+func (o *Orchestrator) EndConversation() {
+	o.session.end()
+	o.history.end()
 }
 
 func (o *Orchestrator) buildContext(results []*models.RetrievalResult) string {
@@ -195,7 +433,7 @@ func (o *Orchestrator) buildContext(results []*models.RetrievalResult) string {
 	return builder.String()
 }
 
-func (o *Orchestrator) buildMessages(query, context string) []models.Message {
+func (o *Orchestrator) buildMessages(query, context, summary string, history []models.Message) []models.Message {
 	messages := []models.Message{
 		{
 			Role:    "system",
@@ -203,6 +441,15 @@ func (o *Orchestrator) buildMessages(query, context string) []models.Message {
 		},
 	}
 
+	// Earlier turns, so follow-ups like "and the second one?" make sense
+	if summary != "" {
+		messages = append(messages, models.Message{
+			Role:    "system",
+			Content: "Summary of the conversation so far: " + summary,
+		})
+	}
+	messages = append(messages, history...)
+
 	// Build user message with context
 	var userContent string
 	if context != "" {
@@ -248,5 +495,13 @@ func (o *Orchestrator) Stop() error {
 		logger.Errorf("Error closing retriever: %v", err)
 	}
 
+	if err := o.indexer.Close(); err != nil {
+		logger.Errorf("Error closing indexer: %v", err)
+	}
+
+	if err := o.history.Close(); err != nil {
+		logger.Errorf("Error closing conversation history: %v", err)
+	}
+
 	return nil
 }
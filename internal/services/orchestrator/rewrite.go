@@ -0,0 +1,129 @@
+package orchestrator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/services/llm"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+const rewriteMaxTokens = 200
+
+// listMarker matches bullets and numbering the LLM may add anyway
+var listMarker = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s+`)
+
+// rewritePrompt asks the LLM for search queries, one per line
+const rewritePrompt = `You write search queries for a personal knowledge base.
+Resolve references like "it", "that" or "the second one" using the conversation, so every query makes sense on its own.
+Reply with one query per line and nothing else.`
+
+// queryRewriter turns the question as asked into what to search for: a
+// follow-up is condensed with the conversation into a standalone query,
+// optionally with paraphrases that catch chunks worded differently
+type queryRewriter struct {
+	client      llm.Client
+	condense    bool
+	paraphrases int
+}
+
+func newQueryRewriter(cfg *config.Config, client llm.Client) *queryRewriter {
+	return &queryRewriter{
+		client:      client,
+		condense:    cfg.Retrieval.RewriteQueries,
+		paraphrases: max(cfg.Retrieval.MultiQuery, 0),
+	}
+}
+
+// rewrite returns the queries to search for, the standalone question first.
+// It falls back to the question as asked if the LLM fails.
+func (q *queryRewriter) rewrite(question, summary string, history []models.Message) []string {
+	logger := utils.GetLogger()
+
+	condense := q.condense && (summary != "" || len(history) > 0)
+	if !condense && q.paraphrases == 0 {
+		return []string{question}
+	}
+
+	var prompt strings.Builder
+	if summary != "" {
+		prompt.WriteString("Earlier in the conversation: " + summary + "\n\n")
+	}
+	for _, msg := range history {
+		role := "User"
+		if msg.Role == "assistant" {
+			role = "Assistant"
+		}
+		prompt.WriteString(role + ": " + msg.Content + "\n")
+	}
+	prompt.WriteString("\nLatest question: " + question + "\n\n")
+
+	switch {
+	case condense && q.paraphrases > 0:
+		fmt.Fprintf(&prompt, "Write the latest question as a standalone search query, then %d differently worded versions of that query.", q.paraphrases)
+	case condense:
+		prompt.WriteString("Write the latest question as a standalone search query.")
+	default:
+		fmt.Fprintf(&prompt, "Write %d differently worded versions of the latest question.", q.paraphrases)
+	}
+
+	resp, err := q.client.Generate(&models.LLMRequest{
+		Messages: []models.Message{
+			{Role: "system", Content: rewritePrompt},
+			{Role: "user", Content: prompt.String()},
+		},
+		MaxTokens:   rewriteMaxTokens,
+		Temperature: 0,
+	})
+	if err != nil {
+		logger.Warnf("Failed to rewrite search query, searching for the question as asked: %v", err)
+		return []string{question}
+	}
+
+	lines := parseQueryLines(resp.Content)
+	if !condense {
+		lines = append([]string{question}, lines...)
+	}
+	queries := dedupeQueries(lines, 1+q.paraphrases)
+	if len(queries) == 0 {
+		return []string{question}
+	}
+
+	logger.Debugf("Search queries for %q: %q", question, queries)
+	return queries
+}
+
+// parseQueryLines splits an LLM reply into queries, dropping list markers
+// and quotes
+func parseQueryLines(reply string) []string {
+	var queries []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.Trim(line, "\"'` ")
+		if line != "" {
+			queries = append(queries, line)
+		}
+	}
+	return queries
+}
+
+// dedupeQueries keeps the first limit queries that differ other than in case
+func dedupeQueries(queries []string, limit int) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, query := range queries {
+		key := strings.ToLower(query)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, query)
+		if len(unique) == limit {
+			break
+		}
+	}
+	return unique
+}
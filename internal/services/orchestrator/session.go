@@ -0,0 +1,57 @@
+package orchestrator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+)
+
+// session tracks the follow-up window of a conversation: after each answer,
+// utterances are taken as follow-ups without the wake word until the window
+// passes in silence or the user says a stop phrase
+type session struct {
+	window time.Duration // 0 when follow-ups are off
+
+	mu       sync.Mutex
+	deadline time.Time // follow-ups must start before this
+}
+
+func newSession(cfg *config.Config) *session {
+	return &session{
+		window: time.Duration(max(cfg.Conversation.FollowUpSeconds, 0)) * time.Second,
+	}
+}
+
+// open starts or restarts the follow-up window at now
+func (s *session) open(now time.Time) {
+	if s.window == 0 {
+		return
+	}
+	s.mu.Lock()
+	s.deadline = now.Add(s.window)
+	s.mu.Unlock()
+}
+
+// resume restarts the window at now unless the conversation was ended
+func (s *session) resume(now time.Time) {
+	s.mu.Lock()
+	if !s.deadline.IsZero() {
+		s.deadline = now.Add(s.window)
+	}
+	s.mu.Unlock()
+}
+
+// accepts reports whether speech that started at t is a follow-up
+func (s *session) accepts(t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return t.Before(s.deadline)
+}
+
+// end closes the follow-up window
+func (s *session) end() {
+	s.mu.Lock()
+	s.deadline = time.Time{}
+	s.mu.Unlock()
+}
@@ -0,0 +1,301 @@
+package orchestrator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+)
+
+// wakeWordMatcher finds wake phrases in transcripts. Punctuation and spacing
+// are ignored, so "Sir." and "sir," match "Sir", and with fuzzy matching on
+// near misses like "Sur" match too.
+type wakeWordMatcher struct {
+	common        []wakePhrase            // checked in every language
+	byLanguage    map[string][]wakePhrase // extra phrases by language code
+	matchType     string
+	caseSensitive bool
+	fuzzy         bool
+	maxEdits      int // per word; 0 picks a limit by word length
+	longest       int // words in the longest phrase
+}
+
+// wakePhrase is a wake phrase split into words
+type wakePhrase struct {
+	text  string
+	words []string
+}
+
+// wakeMatch is where a wake phrase was found in a transcript
+type wakeMatch struct {
+	phrase     string
+	start, end int // byte offsets of the phrase in the transcript
+}
+
+// transcriptWord is a word of a transcript and its byte offsets
+type transcriptWord struct {
+	text       string
+	start, end int
+}
+
+func newWakeWordMatcher(cfg config.WakeWordConfig) *wakeWordMatcher {
+	m := &wakeWordMatcher{
+		byLanguage:    make(map[string][]wakePhrase),
+		matchType:     cfg.MatchType,
+		caseSensitive: cfg.CaseSensitive,
+		fuzzy:         cfg.Fuzzy,
+		maxEdits:      cfg.MaxEditDistance,
+	}
+
+	add := func(phrases []wakePhrase, text string) []wakePhrase {
+		var words []string
+		for _, w := range m.words(text) {
+			words = append(words, w.text)
+		}
+		if len(words) == 0 {
+			return phrases
+		}
+		m.longest = max(m.longest, len(words))
+		return append(phrases, wakePhrase{text: text, words: words})
+	}
+
+	m.common = add(m.common, cfg.Word)
+	for language, phrases := range cfg.Phrases {
+		language = strings.ToLower(language)
+		for _, phrase := range phrases {
+			m.byLanguage[language] = add(m.byLanguage[language], phrase)
+		}
+	}
+	return m
+}
+
+// newStopPhraseMatcher matches transcripts that are nothing but a stop
+// phrase, with the wake word's case and fuzzy settings
+func newStopPhraseMatcher(phrases []string, wake config.WakeWordConfig) *wakeWordMatcher {
+	return newWakeWordMatcher(config.WakeWordConfig{
+		Phrases:         map[string][]string{"": phrases},
+		CaseSensitive:   wake.CaseSensitive,
+		MatchType:       "exact",
+		Fuzzy:           wake.Fuzzy,
+		MaxEditDistance: wake.MaxEditDistance,
+	})
+}
+
+// find returns the wake phrase in text allowed by the match type, preferring
+// the earliest and then the longest. Phrases for language are checked along
+// with the wake word; when language is unknown or has no phrases of its own,
+// every language's phrases are.
+func (m *wakeWordMatcher) find(text, language string) (wakeMatch, bool) {
+	words := m.words(text)
+	if len(words) == 0 {
+		return wakeMatch{}, false
+	}
+
+	var best wakeMatch
+	bestAt, bestLen := len(words), 0
+	for _, phrase := range m.phrases(language) {
+		for at := 0; at+len(phrase.words) <= len(words); at++ {
+			if at > bestAt || (at == bestAt && len(phrase.words) <= bestLen) {
+				break
+			}
+			switch m.matchType {
+			case "exact":
+				if at > 0 || len(phrase.words) != len(words) {
+					continue
+				}
+			case "contains":
+			default: // prefix
+				if at > 0 {
+					continue
+				}
+			}
+			if !m.matchesAt(words[at:], phrase) {
+				continue
+			}
+			bestAt, bestLen = at, len(phrase.words)
+			best = wakeMatch{
+				phrase: phrase.text,
+				start:  words[at].start,
+				end:    words[at+len(phrase.words)-1].end,
+			}
+			break
+		}
+	}
+	return best, bestLen > 0
+}
+
+// phrases returns the phrases to look for in a transcript in language
+func (m *wakeWordMatcher) phrases(language string) []wakePhrase {
+	if phrases, ok := m.byLanguage[strings.ToLower(language)]; ok {
+		return append(append([]wakePhrase(nil), m.common...), phrases...)
+	}
+
+	all := append([]wakePhrase(nil), m.common...)
+	for _, phrases := range m.byLanguage {
+		all = append(all, phrases...)
+	}
+	return all
+}
+
+// remove returns text without a wake phrase found in it, dropping the
+// punctuation that set the phrase apart ("What time is it, sir?" becomes
+// "What time is it")
+func (m *wakeWordMatcher) remove(text string, match wakeMatch) string {
+	separator := func(r rune) bool { return !isWordRune(r) }
+	before := strings.TrimRightFunc(text[:match.start], separator)
+	after := strings.TrimLeftFunc(text[match.end:], separator)
+
+	switch {
+	case before == "":
+		return after
+	case after == "":
+		return before
+	default:
+		return before + " " + after
+	}
+}
+
+// ruledOut reports whether a partial transcript is long enough to show that
+// a wake phrase required at its start isn't there, allowing grace words for
+// revisions of the hypothesis
+func (m *wakeWordMatcher) ruledOut(text, language string, grace int) bool {
+	if m.matchType == "contains" {
+		return false // it could still come later
+	}
+	if len(m.words(text)) <= m.longest+grace {
+		return false
+	}
+	_, found := m.find(text, language)
+	return !found
+}
+
+// matchesAt reports whether words start with phrase
+func (m *wakeWordMatcher) matchesAt(words []transcriptWord, phrase wakePhrase) bool {
+	for i, want := range phrase.words {
+		if !m.wordMatches(words[i].text, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// wordMatches compares a transcript word with a phrase word. A fuzzy match
+// must be within the edit limit and, for Latin-script words, sound the same,
+// so "Sur" passes for "Sir" but "six" doesn't.
+func (m *wakeWordMatcher) wordMatches(heard, want string) bool {
+	if heard == want {
+		return true
+	}
+	if !m.fuzzy {
+		return false
+	}
+
+	limit := m.maxEdits
+	if limit <= 0 {
+		switch n := utf8.RuneCountInString(want); {
+		case n < 3:
+			return false // too short to tell a near miss from another word
+		case n < 8:
+			limit = 1
+		default:
+			limit = 2
+		}
+	}
+	if editDistance(heard, want) > limit {
+		return false
+	}
+
+	heardKey, wantKey := soundex(heard), soundex(want)
+	return heardKey == "" || wantKey == "" || heardKey == wantKey
+}
+
+// words splits text into words: runs of letters, digits and combining marks
+// (the vowel signs of scripts like Devanagari)
+func (m *wakeWordMatcher) words(text string) []transcriptWord {
+	var words []transcriptWord
+	start := -1
+	for i, r := range text {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			words = append(words, m.word(text, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, m.word(text, start, len(text)))
+	}
+	return words
+}
+
+func (m *wakeWordMatcher) word(text string, start, end int) transcriptWord {
+	word := text[start:end]
+	if !m.caseSensitive {
+		word = strings.ToLower(word)
+	}
+	return transcriptWord{text: word, start: start, end: end}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// soundex returns the Soundex code of an English word, or "" for words with
+// characters outside A-Z
+func soundex(word string) string {
+	const codes = "01230120022455012623010202" // a-z
+
+	word = strings.ToLower(word)
+	if word == "" {
+		return ""
+	}
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+
+	key := []byte{word[0] - 'a' + 'A'}
+	last := codes[word[0]-'a']
+	for i := 1; i < len(word) && len(key) < 4; i++ {
+		c := word[i]
+		code := codes[c-'a']
+		switch {
+		case c == 'h' || c == 'w':
+			continue // doesn't separate letters with the same code
+		case code == '0':
+			last = '0'
+		case code != last:
+			key = append(key, code)
+			last = code
+		}
+	}
+	for len(key) < 4 {
+		key = append(key, '0')
+	}
+	return string(key)
+}
@@ -0,0 +1,110 @@
+package retriever
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// RebuildFunc re-indexes all content, passing the resulting chunks to add
+type RebuildFunc func(add func(chunks []*models.Chunk) error) error
+
+// BeginMigration creates the new index next to the existing one, so chunks
+// indexed from now on already go to it. Migrate fills it and swaps it in.
+func (r *Retriever) BeginMigration() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next != nil {
+		return nil
+	}
+
+	// Discard leftovers of an interrupted migration
+	nextPath := r.cfg.Retrieval.DBPath + ".next"
+	if err := os.Remove(nextPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale migration index: %w", err)
+	}
+
+	next, err := NewVectorStore(nextPath, r.info)
+	if err != nil {
+		return fmt.Errorf("failed to create migration index: %w", err)
+	}
+	r.next = next
+	return nil
+}
+
+// Migrate builds a new index with the current embedding and chunking
+// parameters next to the existing one, then atomically swaps it in.
+// Queries keep being served from the old index until the swap.
+func (r *Retriever) Migrate(rebuild RebuildFunc) error {
+	logger := utils.GetLogger()
+	nextPath := r.cfg.Retrieval.DBPath + ".next"
+
+	if err := r.BeginMigration(); err != nil {
+		return err
+	}
+	r.mu.RLock()
+	next := r.next
+	r.mu.RUnlock()
+
+	logger.Infof("Migrating vector store to %s", r.info)
+
+	if err := rebuild(next.AddChunks); err != nil {
+		r.mu.Lock()
+		r.next = nil
+		r.mu.Unlock()
+
+		next.Close()
+		os.Remove(nextPath)
+		return fmt.Errorf("failed to rebuild index: %w", err)
+	}
+
+	if err := r.swap(next, nextPath); err != nil {
+		return err
+	}
+
+	_, totalChunks, _ := r.GetStats()
+	logger.Infof("Vector store migration complete: %d chunks", totalChunks)
+	return nil
+}
+
+// swap replaces the current store with the migrated one. Both databases are
+// closed before the rename so it also works where open files can't be replaced.
+func (r *Retriever) swap(next *VectorStore, nextPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dbPath := r.cfg.Retrieval.DBPath
+	r.next = nil
+
+	if err := next.Close(); err != nil {
+		return fmt.Errorf("failed to close migration index: %w", err)
+	}
+	if err := r.store.Close(); err != nil {
+		return fmt.Errorf("failed to close vector store: %w", err)
+	}
+
+	if err := os.Rename(nextPath, dbPath); err != nil {
+		// Keep serving from the old index
+		store, openErr := NewVectorStore(dbPath, r.info)
+		if openErr != nil {
+			return fmt.Errorf("failed to swap index: %v; failed to reopen old index: %w", err, openErr)
+		}
+		r.store = store
+		return fmt.Errorf("failed to swap index: %w", err)
+	}
+
+	store, err := NewVectorStore(dbPath, r.info)
+	if err != nil {
+		return fmt.Errorf("failed to open migrated index: %w", err)
+	}
+
+	r.store = store
+	if r.queryEmbedder != r.embedder {
+		r.queryEmbedder.Close()
+		r.queryEmbedder = r.embedder
+	}
+	return nil
+}
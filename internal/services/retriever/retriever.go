@@ -2,63 +2,148 @@ package retriever
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/shashwatssp/deeprecall/internal/config"
 	"github.com/shashwatssp/deeprecall/internal/models"
 	"github.com/shashwatssp/deeprecall/internal/services/context"
+	"github.com/shashwatssp/deeprecall/internal/utils"
 )
 
 type Retriever struct {
-	store    *VectorStore
-	embedder *context.Embedder
-	cfg      *config.Config
+	mu            sync.RWMutex
+	store         *VectorStore
+	next          *VectorStore // Index being built by a running migration
+	embedder      *context.Embedder
+	queryEmbedder *context.Embedder // Embeds queries in the space of the current store
+	info          context.IndexInfo
+	cfg           *config.Config
 }
 
 func NewRetriever(cfg *config.Config) (*Retriever, error) {
-	store, err := NewVectorStore(cfg.Retrieval.DBPath)
+	info := context.IndexInfoFromConfig(cfg)
+	store, err := NewVectorStore(cfg.Retrieval.DBPath, info)
 	if err != nil {
 		return nil, err
 	}
 
+	embedder := context.NewEmbedder(cfg)
+	queryEmbedder := embedder
+
+	if store.Mismatch() {
+		stored := store.IndexInfo()
+		utils.GetLogger().Warnf("Vector store was built with %s but config requires %s; migration needed",
+			stored, info)
+
+		// Keep answering queries from the old index until the migration swaps it out
+		if stored.Model != "" && (stored.Model != info.Model || stored.Dimension != info.Dimension) {
+			oldCfg := *cfg
+			oldCfg.Context.Embeddings.Model = stored.Model
+			oldCfg.Context.Embeddings.Dimension = stored.Dimension
+			queryEmbedder = context.NewEmbedder(&oldCfg)
+		}
+	}
+
 	return &Retriever{
-		store:    store,
-		embedder: context.NewEmbedder(cfg),
-		cfg:      cfg,
+		store:         store,
+		embedder:      embedder,
+		queryEmbedder: queryEmbedder,
+		info:          info,
+		cfg:           cfg,
 	}, nil
 }
 
 // Retrieve finds relevant chunks for a query
 func (r *Retriever) Retrieve(query string) ([]*models.RetrievalResult, error) {
-	// Generate query embedding
-	queryEmb, err := r.embedder.CreateEmbedding(query)
+	return r.RetrieveAll([]string{query})
+}
+
+// RetrieveAll finds relevant chunks for several phrasings of the same
+// question, merging the results. A chunk found by more than one query keeps
+// its best score.
+func (r *Retriever) RetrieveAll(queries []string) ([]*models.RetrievalResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Generate query embeddings
+	embeddings, err := r.queryEmbedder.EmbedTexts(queries)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create query embedding: %w", err)
 	}
 
 	// Search vector store
-	results, err := r.store.Search(
-		queryEmb,
-		r.cfg.Retrieval.TopK,
-		r.cfg.Retrieval.SimilarityThreshold,
-	)
-	if err != nil {
-		return nil, err
+	best := make(map[string]*models.RetrievalResult)
+	for _, queryEmb := range embeddings {
+		results, err := r.store.Search(
+			queryEmb,
+			r.cfg.Retrieval.TopK,
+			r.cfg.Retrieval.SimilarityThreshold,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			if seen, ok := best[result.Chunk.ID]; !ok || result.Score > seen.Score {
+				best[result.Chunk.ID] = result
+			}
+		}
 	}
 
-	return results, nil
+	merged := make([]*models.RetrievalResult, 0, len(best))
+	for _, result := range best {
+		merged = append(merged, result)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	if len(merged) > r.cfg.Retrieval.TopK {
+		merged = merged[:r.cfg.Retrieval.TopK]
+	}
+
+	return merged, nil
 }
 
-// IndexChunks adds chunks to the retriever
+// IndexChunks adds chunks to the retriever. While a migration is running,
+// chunks go to the new index since they are built with the new parameters.
 func (r *Retriever) IndexChunks(chunks []*models.Chunk) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.next != nil {
+		return r.next.AddChunks(chunks)
+	}
 	return r.store.AddChunks(chunks)
 }
 
+// NeedsMigration reports whether the vector store was built with different
+// embedding or chunking parameters than the current configuration
+func (r *Retriever) NeedsMigration() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.store.Mismatch()
+}
+
 // GetStats returns retriever statistics
 func (r *Retriever) GetStats() (totalDocs, totalChunks int, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	return r.store.GetStats()
 }
 
 // Close closes the retriever
 func (r *Retriever) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next != nil {
+		r.next.Close()
+	}
+	if r.queryEmbedder != r.embedder {
+		r.queryEmbedder.Close()
+	}
+	r.embedder.Close()
 	return r.store.Close()
 }
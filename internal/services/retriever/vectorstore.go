@@ -8,23 +8,29 @@ import (
 	"sync"
 
 	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/services/context"
 	bolt "go.etcd.io/bbolt"
 )
 
 var (
 	chunksBucket = []byte("chunks")
 	metaBucket   = []byte("metadata")
+	indexInfoKey = []byte("index_info")
 )
 
 // VectorStore provides efficient vector storage and similarity search
 type VectorStore struct {
-	db     *bolt.DB
-	mu     sync.RWMutex
-	memory map[string]*models.Chunk // In-memory cache for faster access
+	db       *bolt.DB
+	mu       sync.RWMutex
+	memory   map[string]*models.Chunk // In-memory cache for faster access
+	info     context.IndexInfo        // Parameters the stored vectors were built with
+	mismatch bool                     // Stored vectors don't match the requested parameters
 }
 
-// NewVectorStore creates a new vector store
-func NewVectorStore(dbPath string) (*VectorStore, error) {
+// NewVectorStore creates a new vector store for vectors built with info.
+// An existing store built with different parameters is opened as-is and
+// reported by Mismatch so it can be migrated.
+func NewVectorStore(dbPath string, info context.IndexInfo) (*VectorStore, error) {
 	db, err := bolt.Open(dbPath, 0600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -47,16 +53,78 @@ func NewVectorStore(dbPath string) (*VectorStore, error) {
 	vs := &VectorStore{
 		db:     db,
 		memory: make(map[string]*models.Chunk),
+		info:   info,
 	}
 
 	// Load chunks into memory for faster retrieval
 	if err := vs.loadIntoMemory(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := vs.checkIndexInfo(info); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return vs, nil
 }
 
+// checkIndexInfo compares the recorded index parameters with the requested
+// ones, recording them for a new store
+func (vs *VectorStore) checkIndexInfo(info context.IndexInfo) error {
+	var stored *context.IndexInfo
+	err := vs.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(indexInfoKey)
+		if data == nil {
+			return nil
+		}
+
+		stored = &context.IndexInfo{}
+		buf := append([]byte(nil), data...)
+		return gob.NewDecoder(&bufferWrapper{&buf}).Decode(stored)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read index metadata: %w", err)
+	}
+
+	switch {
+	case stored != nil:
+		vs.info = *stored
+		vs.mismatch = *stored != info
+	case len(vs.memory) > 0:
+		// Built before index metadata was recorded: parameters are unknown
+		vs.info = context.IndexInfo{}
+		vs.mismatch = true
+	default:
+		return vs.writeIndexInfo(info)
+	}
+
+	return nil
+}
+
+func (vs *VectorStore) writeIndexInfo(info context.IndexInfo) error {
+	var buf []byte
+	if err := gob.NewEncoder(&bufferWrapper{&buf}).Encode(info); err != nil {
+		return fmt.Errorf("failed to encode index metadata: %w", err)
+	}
+
+	return vs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(indexInfoKey, buf)
+	})
+}
+
+// IndexInfo returns the parameters the stored vectors were built with
+func (vs *VectorStore) IndexInfo() context.IndexInfo {
+	return vs.info
+}
+
+// Mismatch reports whether the stored vectors were built with parameters
+// other than the ones the store was opened with
+func (vs *VectorStore) Mismatch() bool {
+	return vs.mismatch
+}
+
 // Close closes the database
 func (vs *VectorStore) Close() error {
 	return vs.db.Close()
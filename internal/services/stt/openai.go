@@ -0,0 +1,198 @@
+package stt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+const defaultTranscriptionTimeout = 60 * time.Second
+
+// OpenAIService implements STT with an OpenAI-compatible
+// /audio/transcriptions endpoint
+type OpenAIService struct {
+	cfg     *config.Config
+	client  *openai.Client
+	model   string
+	timeout time.Duration
+}
+
+// NewOpenAIService creates a client for the configured transcription endpoint
+func NewOpenAIService(cfg *config.Config) (*OpenAIService, error) {
+	apiKey := cfg.STT.APIKey
+	if apiKey == "" {
+		apiKey = cfg.LLM.APIKey
+	}
+
+	clientConfig := openai.DefaultConfig(apiKey)
+	if cfg.STT.BaseURL != "" {
+		clientConfig.BaseURL = cfg.STT.BaseURL
+	}
+
+	model := cfg.STT.Model
+	if model == "" {
+		model = openai.Whisper1
+	}
+
+	timeout := time.Duration(cfg.STT.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTranscriptionTimeout
+	}
+
+	utils.GetLogger().Infof("OpenAI transcription service initialized with model %s at %s", model, clientConfig.BaseURL)
+
+	return &OpenAIService{
+		cfg:     cfg,
+		client:  openai.NewClientWithConfig(clientConfig),
+		model:   model,
+		timeout: timeout,
+	}, nil
+}
+
+// Transcribe uploads 16-bit mono PCM audio as WAV and returns the
+// transcription with segment timestamps
+func (s *OpenAIService) Transcribe(audioData []byte) (*models.TranscriptionResult, error) {
+	startTime := time.Now()
+	logger := utils.GetLogger()
+
+	if len(audioData) == 0 {
+		return nil, fmt.Errorf("empty audio data")
+	}
+
+	sampleRate := s.cfg.Audio.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = whisperSampleRate
+	}
+	if limit := 2 * s.cfg.STT.MaxDurationSeconds * sampleRate; limit > 0 && len(audioData) > limit {
+		logger.Warnf("Audio is %.1fs long, transcribing only the first %ds",
+			float64(len(audioData))/float64(2*sampleRate), s.cfg.STT.MaxDurationSeconds)
+		audioData = audioData[:limit]
+	}
+
+	req := openai.AudioRequest{
+		Model:                  s.model,
+		FilePath:               "audio.wav",
+		Reader:                 bytes.NewReader(wavFile(audioData, sampleRate)),
+		Format:                 openai.AudioResponseFormatVerboseJSON,
+		TimestampGranularities: []openai.TranscriptionTimestampGranularity{openai.TranscriptionTimestampGranularitySegment},
+	}
+	// Without a hint the service detects the language itself
+	if language := s.cfg.STT.Language; language != "" && language != "auto" {
+		req.Language = language
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if s.cfg.STT.Translate {
+		// Translation always produces English and has no timestamp option
+		req.Language, req.TimestampGranularities = "", nil
+		resp, err := s.client.CreateTranslation(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate audio: %w", err)
+		}
+		result := transcriptionFromResponse(resp)
+		result.Language = "en"
+		result.Duration = time.Since(startTime)
+		return result, nil
+	}
+
+	resp, err := s.client.CreateTranscription(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transcribe audio: %w", err)
+	}
+
+	result := transcriptionFromResponse(resp)
+	if result.Language == "" {
+		result.Language = req.Language
+	}
+	result.Duration = time.Since(startTime)
+
+	logger.Debugf("Transcribed %d segments in %v (language %s)", len(result.Segments), result.Duration, result.Language)
+	return result, nil
+}
+
+// TranscribeStream waits for stream to close and transcribes the audio once
+func (s *OpenAIService) TranscribeStream(stream <-chan []byte) (*models.TranscriptionResult, error) {
+	return s.Transcribe(collectAudio(stream))
+}
+
+// TranscribeLive transcribes audio as it arrives in a rolling window
+func (s *OpenAIService) TranscribeLive(ctx context.Context, stream <-chan []byte) <-chan StreamUpdate {
+	return newRollingTranscriber(s.cfg, s.Transcribe).run(ctx, stream)
+}
+
+// transcriptionFromResponse maps a verbose_json response onto a result.
+// Segment confidence is the probability implied by the mean token log
+// probability; the overall confidence weights segments by length.
+func transcriptionFromResponse(resp openai.AudioResponse) *models.TranscriptionResult {
+	result := &models.TranscriptionResult{
+		Text:     strings.TrimSpace(resp.Text),
+		Language: languageCode(resp.Language),
+	}
+
+	var weighted, total float64
+	for _, seg := range resp.Segments {
+		segment := models.TranscriptSegment{
+			Start: time.Duration(seg.Start * float64(time.Second)),
+			End:   time.Duration(seg.End * float64(time.Second)),
+			Text:  strings.TrimSpace(seg.Text),
+		}
+		if seg.AvgLogprob != 0 {
+			segment.Confidence = math.Exp(seg.AvgLogprob)
+		}
+		result.Segments = append(result.Segments, segment)
+
+		length := math.Max(seg.End-seg.Start, 0)
+		weighted += segment.Confidence * length
+		total += length
+	}
+
+	if total > 0 {
+		result.Confidence = weighted / total
+	}
+	return result
+}
+
+// languageCode converts the language names OpenAI reports ("english") to
+// codes, leaving codes and unknown names as they are
+func languageCode(language string) string {
+	if code, ok := whisperLanguages[language]; ok {
+		return code
+	}
+	return language
+}
+
+// whisperLanguages maps the names OpenAI returns to ISO 639-1 codes for the
+// most common languages
+var whisperLanguages = map[string]string{
+	"english":    "en",
+	"hindi":      "hi",
+	"spanish":    "es",
+	"french":     "fr",
+	"german":     "de",
+	"italian":    "it",
+	"portuguese": "pt",
+	"dutch":      "nl",
+	"russian":    "ru",
+	"chinese":    "zh",
+	"japanese":   "ja",
+	"korean":     "ko",
+	"arabic":     "ar",
+	"turkish":    "tr",
+	"polish":     "pl",
+	"ukrainian":  "uk",
+	"bengali":    "bn",
+	"tamil":      "ta",
+	"telugu":     "te",
+	"marathi":    "mr",
+	"urdu":       "ur",
+}
@@ -0,0 +1,118 @@
+package stt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+)
+
+func TestOpenAITranscribe(t *testing.T) {
+	const sampleRate = 16000
+	audio := make([]byte, 2*sampleRate) // one second of silence
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" {
+			t.Errorf("request to %s, want /audio/transcriptions", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse multipart form: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for field, want := range map[string]string{
+			"model":           "whisper-test",
+			"language":        "hi",
+			"response_format": "verbose_json",
+		} {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("%s = %q, want %q", field, got, want)
+			}
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("no file in request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			t.Errorf("failed to read file: %v", err)
+		}
+		if header.Filename != "audio.wav" {
+			t.Errorf("filename = %q, want audio.wav", header.Filename)
+		}
+		if len(data) != 44+len(audio) || string(data[:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " {
+			t.Errorf("file is not a %d byte WAV: %q", 44+len(audio), data[:min(len(data), 16)])
+		} else if rate := binary.LittleEndian.Uint32(data[24:28]); rate != sampleRate {
+			t.Errorf("WAV sample rate = %d, want %d", rate, sampleRate)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"task": "transcribe",
+			"language": "hindi",
+			"duration": 3.0,
+			"text": " Namaste. Kaise ho? ",
+			"segments": [
+				{"id": 0, "start": 0.0, "end": 1.0, "text": " Namaste.", "avg_logprob": -0.1},
+				{"id": 1, "start": 1.0, "end": 3.0, "text": " Kaise ho?", "avg_logprob": -0.5}
+			]
+		}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.STT.BaseURL = server.URL
+	cfg.STT.APIKey = "test-key"
+	cfg.STT.Model = "whisper-test"
+	cfg.STT.Language = "hi"
+	cfg.Audio.SampleRate = sampleRate
+
+	s, err := NewOpenAIService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Transcribe(audio)
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+
+	if result.Text != "Namaste. Kaise ho?" {
+		t.Errorf("Text = %q", result.Text)
+	}
+	if result.Language != "hi" {
+		t.Errorf("Language = %q, want hi", result.Language)
+	}
+
+	if len(result.Segments) != 2 {
+		t.Fatalf("got %d segments, want 2", len(result.Segments))
+	}
+	first, second := result.Segments[0], result.Segments[1]
+	if first.Text != "Namaste." || first.Start != 0 || first.End != time.Second {
+		t.Errorf("first segment = %+v", first)
+	}
+	if second.Text != "Kaise ho?" || second.Start != time.Second || second.End != 3*time.Second {
+		t.Errorf("second segment = %+v", second)
+	}
+	if want := math.Exp(-0.1); math.Abs(first.Confidence-want) > 1e-9 {
+		t.Errorf("first segment confidence = %v, want %v", first.Confidence, want)
+	}
+
+	// Segments are weighted by length: one second at e^-0.1, two at e^-0.5
+	if want := (math.Exp(-0.1) + 2*math.Exp(-0.5)) / 3; math.Abs(result.Confidence-want) > 1e-9 {
+		t.Errorf("Confidence = %v, want %v", result.Confidence, want)
+	}
+}
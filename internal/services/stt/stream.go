@@ -0,0 +1,258 @@
+package stt
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+const (
+	defaultStreamStep   = time.Second
+	defaultStreamWindow = 15 * time.Second
+)
+
+// StreamUpdate is the transcription of the audio received so far. Partial
+// updates may still be revised; the Final one covers the whole stream.
+type StreamUpdate struct {
+	Result *models.TranscriptionResult
+	Final  bool
+	Err    error // set on the last update when transcription failed
+}
+
+// rollingTranscriber turns a one-shot Transcribe into a stream transcriber.
+// Every step of new audio it re-transcribes the uncommitted window and emits
+// a partial hypothesis. Once the window is full, all but its last segment
+// are committed and the window restarts after them, so each call sees at
+// most window of audio however long the stream runs.
+type rollingTranscriber struct {
+	transcribe func([]byte) (*models.TranscriptionResult, error)
+	sampleRate int
+	step       int // bytes of new audio between hypotheses
+	window     int // most bytes transcribed in one call
+}
+
+func newRollingTranscriber(cfg *config.Config, transcribe func([]byte) (*models.TranscriptionResult, error)) *rollingTranscriber {
+	sampleRate := cfg.Audio.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = whisperSampleRate
+	}
+
+	step := time.Duration(cfg.STT.StreamStepMs) * time.Millisecond
+	if step <= 0 {
+		step = defaultStreamStep
+	}
+	window := time.Duration(cfg.STT.StreamWindowSeconds) * time.Second
+	if window <= 0 {
+		window = defaultStreamWindow
+	}
+	// Providers truncate anything longer than max_duration_seconds
+	if limit := time.Duration(cfg.STT.MaxDurationSeconds) * time.Second; limit > 0 && window > limit {
+		window = limit
+	}
+
+	bytesPerSecond := 2 * sampleRate
+	return &rollingTranscriber{
+		transcribe: transcribe,
+		sampleRate: sampleRate,
+		step:       max(int(step.Seconds()*float64(bytesPerSecond))&^1, 2),
+		window:     max(int(window.Seconds()*float64(bytesPerSecond))&^1, 2),
+	}
+}
+
+// run transcribes stream until it closes or ctx is cancelled. The returned
+// channel is closed after the final update.
+func (r *rollingTranscriber) run(ctx context.Context, stream <-chan []byte) <-chan StreamUpdate {
+	updates := make(chan StreamUpdate, 1)
+
+	var mu sync.Mutex
+	var audio []byte
+	arrived := make(chan struct{}, 1)
+	closed := make(chan struct{})
+
+	// Read continuously so a slow transcription never blocks the recorder
+	go func() {
+		defer close(closed)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case chunk, ok := <-stream:
+				if !ok {
+					return
+				}
+				mu.Lock()
+				audio = append(audio, chunk...)
+				mu.Unlock()
+				select {
+				case arrived <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer close(updates)
+
+		var committed []models.TranscriptSegment
+		var language string
+		start := 0   // byte offset of the uncommitted window
+		decoded := 0 // bytes of audio covered by the last hypothesis
+
+		send := func(update StreamUpdate) bool {
+			select {
+			case updates <- update:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// decode transcribes the window from start and commits finished
+		// segments, returning the hypothesis for the window
+		decode := func(snapshot []byte, final bool) ([]models.TranscriptSegment, error) {
+			end := min(len(snapshot), start+r.window)
+			full := end-start >= r.window
+
+			result, err := r.transcribe(snapshot[start:end])
+			if err != nil {
+				return nil, err
+			}
+			if result.Language != "" {
+				language = result.Language
+			}
+
+			offset := r.duration(start)
+			segments := result.Segments
+			if len(segments) == 0 && strings.TrimSpace(result.Text) != "" {
+				segments = []models.TranscriptSegment{{End: r.duration(end - start), Text: result.Text, Confidence: result.Confidence}}
+			}
+			for i := range segments {
+				segments[i].Start += offset
+				segments[i].End += offset
+			}
+
+			switch {
+			case final && end == len(snapshot), !full:
+				return segments, nil
+			case len(segments) >= 2:
+				// The last segment may be cut off mid-word; keep it in the window
+				done := segments[:len(segments)-1]
+				committed = append(committed, done...)
+				if next := min(r.offset(done[len(done)-1].End), end); next > start {
+					start = next
+				} else {
+					start = end
+				}
+				return segments[len(segments)-1:], nil
+			default:
+				committed = append(committed, segments...)
+				start = end
+				return nil, nil
+			}
+		}
+
+		for {
+			var streamClosed bool
+			select {
+			case <-ctx.Done():
+				return
+			case <-arrived:
+			case <-closed:
+				streamClosed = true
+			}
+
+			mu.Lock()
+			snapshot := audio
+			mu.Unlock()
+
+			if streamClosed {
+				if ctx.Err() != nil {
+					return
+				}
+				// Transcribe whatever is left, window by window
+				var tail []models.TranscriptSegment
+				for start < len(snapshot) {
+					before := start
+					segments, err := decode(snapshot, true)
+					if err != nil {
+						send(StreamUpdate{Result: r.result(committed, nil, language), Final: true, Err: err})
+						return
+					}
+					tail = segments
+					if start == before {
+						break
+					}
+				}
+				send(StreamUpdate{Result: r.result(committed, tail, language), Final: true})
+				return
+			}
+
+			if len(snapshot)-decoded < r.step || len(snapshot) <= start {
+				continue
+			}
+			decoded = len(snapshot)
+
+			hypothesis, err := decode(snapshot, false)
+			if err != nil {
+				utils.GetLogger().Warnf("Streaming transcription failed, retrying with more audio: %v", err)
+				continue
+			}
+			if !send(StreamUpdate{Result: r.result(committed, hypothesis, language)}) {
+				return
+			}
+		}
+	}()
+
+	return updates
+}
+
+// result joins committed segments and the current hypothesis
+func (r *rollingTranscriber) result(committed, hypothesis []models.TranscriptSegment, language string) *models.TranscriptionResult {
+	segments := append(append([]models.TranscriptSegment(nil), committed...), hypothesis...)
+
+	var text []string
+	var weighted, total float64
+	for _, segment := range segments {
+		if t := strings.TrimSpace(segment.Text); t != "" {
+			text = append(text, t)
+		}
+		length := (segment.End - segment.Start).Seconds()
+		weighted += segment.Confidence * length
+		total += length
+	}
+
+	result := &models.TranscriptionResult{
+		Text:     strings.Join(text, " "),
+		Language: language,
+		Segments: segments,
+	}
+	if total > 0 {
+		result.Confidence = weighted / total
+	}
+	return result
+}
+
+// duration converts a PCM byte count to time
+func (r *rollingTranscriber) duration(bytes int) time.Duration {
+	return time.Duration(bytes/2) * time.Second / time.Duration(r.sampleRate)
+}
+
+// offset converts a time to a PCM byte offset
+func (r *rollingTranscriber) offset(d time.Duration) int {
+	return int(d.Seconds()*float64(r.sampleRate)) * 2
+}
+
+// collectAudio reads stream until it closes
+func collectAudio(stream <-chan []byte) []byte {
+	var audio []byte
+	for chunk := range stream {
+		audio = append(audio, chunk...)
+	}
+	return audio
+}
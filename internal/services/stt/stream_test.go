@@ -0,0 +1,232 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// Audio in these tests runs at 1000 samples a second. Every sample of second
+// n holds the value n, so the fake transcriber can tell which seconds it was
+// given however the stream is windowed.
+const streamTestRate = 1000
+
+func streamTestConfig(stepMs, windowSeconds int) *config.Config {
+	cfg := &config.Config{}
+	cfg.Audio.SampleRate = streamTestRate
+	cfg.STT.StreamStepMs = stepMs
+	cfg.STT.StreamWindowSeconds = windowSeconds
+	return cfg
+}
+
+// secondOfAudio returns one second of audio marked with n
+func secondOfAudio(n int) []byte {
+	audio := make([]byte, 2*streamTestRate)
+	for i := range audio {
+		audio[i] = byte(n)
+	}
+	return audio
+}
+
+// fakeTranscriber returns one segment per (partial) second of audio, named
+// after the second, and records the length of every call
+type fakeTranscriber struct {
+	mu    sync.Mutex
+	calls []int
+	fail  bool
+}
+
+func (f *fakeTranscriber) transcribe(audio []byte) (*models.TranscriptionResult, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, len(audio))
+	f.mu.Unlock()
+
+	if f.fail {
+		return nil, errors.New("decoder crashed")
+	}
+
+	const second = 2 * streamTestRate
+	result := &models.TranscriptionResult{Language: "en"}
+	for i := 0; i < len(audio); i += second {
+		end := min(i+second, len(audio))
+		result.Segments = append(result.Segments, models.TranscriptSegment{
+			Start:      time.Duration(i/2) * time.Millisecond,
+			End:        time.Duration(end/2) * time.Millisecond,
+			Text:       fmt.Sprintf("s%d", audio[i]),
+			Confidence: 0.9,
+		})
+	}
+	return result, nil
+}
+
+func (f *fakeTranscriber) longestCall() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	longest := 0
+	for _, n := range f.calls {
+		longest = max(longest, n)
+	}
+	return longest
+}
+
+// collectUpdates reads updates until the channel closes
+func collectUpdates(t *testing.T, updates <-chan StreamUpdate) []StreamUpdate {
+	t.Helper()
+
+	var all []StreamUpdate
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return all
+			}
+			all = append(all, update)
+		case <-timeout:
+			t.Fatalf("updates channel not closed; got %d updates", len(all))
+		}
+	}
+}
+
+func wantTranscript(seconds int) string {
+	words := make([]string, seconds)
+	for i := range words {
+		words[i] = fmt.Sprintf("s%d", i)
+	}
+	return strings.Join(words, " ")
+}
+
+func checkFinal(t *testing.T, updates []StreamUpdate, seconds int) {
+	t.Helper()
+
+	if len(updates) == 0 {
+		t.Fatal("no updates")
+	}
+	for _, update := range updates[:len(updates)-1] {
+		if update.Final {
+			t.Errorf("final update before the last one")
+		}
+	}
+
+	final := updates[len(updates)-1]
+	if !final.Final || final.Err != nil {
+		t.Fatalf("last update = %+v, want a final update without error", final)
+	}
+	if want := wantTranscript(seconds); final.Result.Text != want {
+		t.Errorf("final text = %q, want %q", final.Result.Text, want)
+	}
+	if final.Result.Language != "en" {
+		t.Errorf("language = %q, want en", final.Result.Language)
+	}
+	for i, segment := range final.Result.Segments {
+		if want := time.Duration(i) * time.Second; segment.Start != want || segment.End != want+time.Second {
+			t.Errorf("segment %d spans %v-%v, want %v-%v", i, segment.Start, segment.End, want, want+time.Second)
+		}
+	}
+}
+
+// Audio arriving in real time is committed window by window without
+// repeating or dropping segments at the window boundaries
+func TestRollingTranscriberCommitsAcrossWindows(t *testing.T) {
+	const seconds = 8
+	fake := &fakeTranscriber{}
+	r := newRollingTranscriber(streamTestConfig(500, 3), fake.transcribe)
+
+	stream := make(chan []byte)
+	updates := r.run(context.Background(), stream)
+
+	go func() {
+		for n := 0; n < seconds; n++ {
+			stream <- secondOfAudio(n)
+			time.Sleep(20 * time.Millisecond)
+		}
+		close(stream)
+	}()
+	all := collectUpdates(t, updates)
+
+	checkFinal(t, all, seconds)
+	if len(all) < 2 {
+		t.Errorf("got %d updates, want partial hypotheses before the final one", len(all))
+	}
+	if longest := fake.longestCall(); longest > 3*2*streamTestRate {
+		t.Errorf("transcribed %d bytes in one call, more than the window", longest)
+	}
+}
+
+// Audio still undecoded when the stream closes is drained window by window
+func TestRollingTranscriberDrainsTail(t *testing.T) {
+	const seconds = 10
+	fake := &fakeTranscriber{}
+	// A step longer than the stream means only the drain loop transcribes
+	r := newRollingTranscriber(streamTestConfig(60000, 3), fake.transcribe)
+
+	stream := make(chan []byte, seconds)
+	for n := 0; n < seconds; n++ {
+		stream <- secondOfAudio(n)
+	}
+	close(stream)
+
+	all := collectUpdates(t, r.run(context.Background(), stream))
+
+	checkFinal(t, all, seconds)
+	if len(all) != 1 {
+		t.Errorf("got %d updates, want only the final one", len(all))
+	}
+	if longest := fake.longestCall(); longest > 3*2*streamTestRate {
+		t.Errorf("transcribed %d bytes in one call, more than the window", longest)
+	}
+	if len(fake.calls) < 4 {
+		t.Errorf("drained %d bytes in %d calls, want at least 4", seconds*2*streamTestRate, len(fake.calls))
+	}
+}
+
+func TestRollingTranscriberDrainError(t *testing.T) {
+	fake := &fakeTranscriber{fail: true}
+	r := newRollingTranscriber(streamTestConfig(60000, 3), fake.transcribe)
+
+	stream := make(chan []byte, 1)
+	stream <- secondOfAudio(0)
+	close(stream)
+
+	all := collectUpdates(t, r.run(context.Background(), stream))
+	if len(all) != 1 || !all[0].Final || all[0].Err == nil {
+		t.Fatalf("updates = %+v, want one final update with an error", all)
+	}
+}
+
+// Cancelling ctx closes the updates channel without a final update, even
+// while the recorder is still open
+func TestRollingTranscriberCancel(t *testing.T) {
+	fake := &fakeTranscriber{}
+	r := newRollingTranscriber(streamTestConfig(500, 3), fake.transcribe)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := make(chan []byte)
+	updates := r.run(ctx, stream)
+
+	stream <- secondOfAudio(0)
+	// Wait for the hypothesis so cancellation hits an idle transcriber
+	select {
+	case update := <-updates:
+		if update.Final {
+			t.Errorf("first update is final")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no partial update")
+	}
+	cancel()
+
+	for _, update := range collectUpdates(t, updates) {
+		if update.Final {
+			t.Errorf("final update after cancellation: %+v", update)
+		}
+	}
+}
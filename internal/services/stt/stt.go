@@ -1,6 +1,7 @@
 package stt
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/shashwatssp/deeprecall/internal/config"
@@ -10,7 +11,13 @@ import (
 // Service provides speech-to-text functionality
 type Service interface {
 	Transcribe(audioData []byte) (*models.TranscriptionResult, error)
+	// TranscribeStream waits for stream to close and transcribes the
+	// collected audio in a single request
 	TranscribeStream(stream <-chan []byte) (*models.TranscriptionResult, error)
+	// TranscribeLive transcribes audio as it arrives, sending partial
+	// hypotheses and then a final update before closing the channel. Each
+	// step re-transcribes the rolling window, so paid providers bill for it.
+	TranscribeLive(ctx context.Context, stream <-chan []byte) <-chan StreamUpdate
 }
 
 // NewService creates a new STT service based on configuration
@@ -18,6 +25,10 @@ func NewService(cfg *config.Config) (Service, error) {
 	switch cfg.STT.Provider {
 	case "whisper":
 		return NewWhisperService(cfg)
+	case "whisper-cli":
+		return NewWhisperCLIService(cfg)
+	case "openai":
+		return NewOpenAIService(cfg)
 	case "google":
 		return nil, fmt.Errorf("google STT not implemented yet")
 	case "aws":
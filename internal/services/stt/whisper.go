@@ -1,7 +1,10 @@
 package stt
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/shashwatssp/deeprecall/internal/config"
@@ -9,39 +12,63 @@ import (
 	"github.com/shashwatssp/deeprecall/internal/utils"
 )
 
+// whisperSampleRate is the only input rate whisper.cpp accepts
+const whisperSampleRate = 16000
+
+// whisperModel runs inference with a loaded whisper.cpp model. The cgo
+// implementation lives in whisper_cgo.go and is built with -tags whisper.
+type whisperModel interface {
+	Transcribe(samples []float32, opts whisperOptions) (*models.TranscriptionResult, error)
+	Close() error
+}
+
+// whisperOptions are the per-call inference parameters
+type whisperOptions struct {
+	Language  string // language code, or "auto" to detect
+	Threads   int
+	Translate bool // translate to English
+}
+
 // WhisperService implements STT using Whisper.cpp
 type WhisperService struct {
 	cfg       *config.Config
 	modelPath string
-	// context   *whisper.Context  // TODO: Add when whisper.cpp binding is working
+	model     whisperModel
+	mu        sync.Mutex // inference already uses every configured thread
 }
 
-// NewWhisperService creates a new Whisper STT service
+// NewWhisperService loads the whisper model, failing if the model file is
+// missing or whisper.cpp support was not compiled in
 func NewWhisperService(cfg *config.Config) (*WhisperService, error) {
 	logger := utils.GetLogger()
 
-	// TODO: Initialize whisper.cpp context
-	// This requires:
-	// 1. CGO enabled
-	// 2. whisper.cpp library compiled
-	// 3. Model file downloaded
+	modelPath := cfg.STT.ModelPath
+	if modelPath == "" {
+		return nil, fmt.Errorf("stt.model_path must be set for the whisper provider")
+	}
+	if _, err := os.Stat(modelPath); err != nil {
+		return nil, fmt.Errorf("whisper model not found at %s (download from https://huggingface.co/ggerganov/whisper.cpp): %w", modelPath, err)
+	}
+
+	if rate := cfg.Audio.SampleRate; rate != 0 && rate != whisperSampleRate {
+		return nil, fmt.Errorf("whisper needs %d Hz audio, but audio.sample_rate is %d", whisperSampleRate, rate)
+	}
 
-	/*
-		ctx := whisper.Whisper_init_from_file(cfg.STT.ModelPath)
-		if ctx == nil {
-			return nil, fmt.Errorf("failed to load whisper model: %s", cfg.STT.ModelPath)
-		}
-	*/
+	model, err := loadWhisperModel(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load whisper model: %w", err)
+	}
 
-	logger.Infof("Whisper service initialized with model: %s", cfg.STT.ModelPath)
+	logger.Infof("Whisper service initialized with model: %s", modelPath)
 
 	return &WhisperService{
 		cfg:       cfg,
-		modelPath: cfg.STT.ModelPath,
+		modelPath: modelPath,
+		model:     model,
 	}, nil
 }
 
-// Transcribe converts audio to text
+// Transcribe converts 16-bit mono PCM audio to text
 func (w *WhisperService) Transcribe(audioData []byte) (*models.TranscriptionResult, error) {
 	startTime := time.Now()
 	logger := utils.GetLogger()
@@ -52,58 +79,48 @@ func (w *WhisperService) Transcribe(audioData []byte) (*models.TranscriptionResu
 
 	logger.Debugf("Transcribing audio: %d bytes", len(audioData))
 
-	// TODO: Implement actual Whisper transcription
-	// This requires whisper.cpp Go bindings
-	/*
-		// Convert audio bytes to float32 samples
-		samples := bytesToFloat32(audioData)
-
-		// Set parameters
-		params := whisper.Whisper_full_default_params(whisper.WHISPER_SAMPLING_GREEDY)
-		params.Language = w.cfg.STT.Language
-		params.Threads = w.cfg.STT.Threads
-		params.Translate = w.cfg.STT.Translate
-
-		// Run inference
-		ret := whisper.Whisper_full(w.context, params, samples, len(samples))
-		if ret != 0 {
-			return nil, fmt.Errorf("whisper inference failed")
-		}
-
-		// Get transcription
-		numSegments := whisper.Whisper_full_n_segments(w.context)
-		var text strings.Builder
-
-		for i := 0; i < numSegments; i++ {
-			segment := whisper.Whisper_full_get_segment_text(w.context, i)
-			text.WriteString(segment)
-			text.WriteString(" ")
-		}
-
-		transcription := strings.TrimSpace(text.String())
-	*/
-
-	// Placeholder for now
-	transcription := "Sir, what is in my context files?"
-	logger.Warnf("Using placeholder transcription (Whisper not yet implemented)")
-
-	return &models.TranscriptionResult{
-		Text:       transcription,
-		Language:   w.cfg.STT.Language,
-		Confidence: 0.95,
-		Duration:   time.Since(startTime),
-	}, nil
+	samples := bytesToFloat32(audioData)
+	if limit := w.cfg.STT.MaxDurationSeconds * whisperSampleRate; limit > 0 && len(samples) > limit {
+		logger.Warnf("Audio is %.1fs long, transcribing only the first %ds",
+			float64(len(samples))/whisperSampleRate, w.cfg.STT.MaxDurationSeconds)
+		samples = samples[:limit]
+	}
+
+	opts := whisperOptions{
+		Language:  w.cfg.STT.Language,
+		Threads:   w.cfg.STT.Threads,
+		Translate: w.cfg.STT.Translate,
+	}
+	if opts.Language == "" {
+		opts.Language = "auto"
+	}
+
+	w.mu.Lock()
+	result, err := w.model.Transcribe(samples, opts)
+	w.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("whisper inference failed: %w", err)
+	}
+
+	result.Duration = time.Since(startTime)
+	logger.Debugf("Transcribed %d segments in %v (language %s)", len(result.Segments), result.Duration, result.Language)
+
+	return result, nil
 }
 
-// TranscribeStream handles streaming audio
+// TranscribeStream waits for stream to close and transcribes the audio once
 func (w *WhisperService) TranscribeStream(stream <-chan []byte) (*models.TranscriptionResult, error) {
-	// Collect all audio chunks
-	var allAudio []byte
-	for chunk := range stream {
-		allAudio = append(allAudio, chunk...)
-	}
+	return w.Transcribe(collectAudio(stream))
+}
+
+// TranscribeLive transcribes audio as it arrives in a rolling window
+func (w *WhisperService) TranscribeLive(ctx context.Context, stream <-chan []byte) <-chan StreamUpdate {
+	return newRollingTranscriber(w.cfg, w.Transcribe).run(ctx, stream)
+}
 
-	return w.Transcribe(allAudio)
+// Close releases the model
+func (w *WhisperService) Close() error {
+	return w.model.Close()
 }
 
 // Helper function to convert bytes to float32 samples
@@ -0,0 +1,104 @@
+//go:build whisper
+
+package stt
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+	"github.com/shashwatssp/deeprecall/internal/models"
+)
+
+// cgoWhisperModel runs whisper.cpp through its Go binding
+type cgoWhisperModel struct {
+	model whisper.Model
+}
+
+func loadWhisperModel(path string) (whisperModel, error) {
+	model, err := whisper.New(path)
+	if err != nil {
+		return nil, err
+	}
+	return &cgoWhisperModel{model: model}, nil
+}
+
+func (m *cgoWhisperModel) Transcribe(samples []float32, opts whisperOptions) (*models.TranscriptionResult, error) {
+	// Contexts carry decoding state, so each call gets a fresh one
+	ctx, err := m.model.NewContext()
+	if err != nil {
+		return nil, err
+	}
+
+	// English-only models (*.en.bin) reject any language setting
+	if m.model.IsMultilingual() {
+		if err := ctx.SetLanguage(opts.Language); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Threads > 0 {
+		ctx.SetThreads(uint(opts.Threads))
+	}
+	ctx.SetTranslate(opts.Translate)
+
+	if err := ctx.Process(samples, nil, nil, nil); err != nil {
+		return nil, err
+	}
+
+	result := &models.TranscriptionResult{Language: ctx.DetectedLanguage()}
+	switch {
+	case !m.model.IsMultilingual():
+		result.Language = "en"
+	case opts.Language != "auto":
+		result.Language = opts.Language
+	}
+
+	var text []string
+	var probSum float64
+	var probCount int
+	for {
+		segment, err := ctx.NextSegment()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var segSum float64
+		var segCount int
+		for _, token := range segment.Tokens {
+			if !ctx.IsText(token) {
+				continue
+			}
+			segSum += float64(token.P)
+			segCount++
+		}
+
+		seg := models.TranscriptSegment{
+			Start: segment.Start,
+			End:   segment.End,
+			Text:  strings.TrimSpace(segment.Text),
+		}
+		if segCount > 0 {
+			seg.Confidence = segSum / float64(segCount)
+		}
+		result.Segments = append(result.Segments, seg)
+		text = append(text, seg.Text)
+
+		probSum += segSum
+		probCount += segCount
+	}
+
+	result.Text = strings.TrimSpace(strings.Join(text, " "))
+	if probCount > 0 {
+		result.Confidence = probSum / float64(probCount)
+	}
+
+	return result, nil
+}
+
+func (m *cgoWhisperModel) Close() error {
+	return m.model.Close()
+}
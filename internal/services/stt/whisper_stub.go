@@ -0,0 +1,10 @@
+//go:build !whisper
+
+package stt
+
+import "fmt"
+
+// loadWhisperModel reports that this binary was built without whisper.cpp
+func loadWhisperModel(path string) (whisperModel, error) {
+	return nil, fmt.Errorf("whisper.cpp support is not compiled in; rebuild with -tags whisper or set stt.provider to whisper-cli")
+}
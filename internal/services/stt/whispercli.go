@@ -0,0 +1,256 @@
+package stt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+	"github.com/shashwatssp/deeprecall/internal/models"
+	"github.com/shashwatssp/deeprecall/internal/utils"
+)
+
+// WhisperCLIService implements STT by running whisper.cpp's command line
+// tool, for builds without CGO
+type WhisperCLIService struct {
+	cfg       *config.Config
+	command   string
+	modelPath string
+	run       utils.CommandRunner
+	plainJSON atomic.Bool // set once the binary turns out not to support -ojf
+}
+
+// whisperCLIOutput is the JSON written by whisper-cli -ojf. Builds older
+// than -ojf write it with -oj, without tokens.
+type whisperCLIOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // milliseconds
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text   string `json:"text"`
+		Tokens []struct {
+			Text string  `json:"text"`
+			P    float64 `json:"p"`
+		} `json:"tokens"`
+	} `json:"transcription"`
+}
+
+// NewWhisperCLIService checks that the whisper binary and model exist
+func NewWhisperCLIService(cfg *config.Config) (*WhisperCLIService, error) {
+	command := cfg.STT.Command
+	if command == "" {
+		command = "whisper-cli"
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return nil, fmt.Errorf("whisper binary %s not found: %w", command, err)
+	}
+
+	modelPath := cfg.STT.ModelPath
+	if modelPath == "" {
+		return nil, fmt.Errorf("stt.model_path must be set for the whisper-cli provider")
+	}
+	if _, err := os.Stat(modelPath); err != nil {
+		return nil, fmt.Errorf("whisper model not found at %s (download from https://huggingface.co/ggerganov/whisper.cpp): %w", modelPath, err)
+	}
+
+	utils.GetLogger().Infof("Whisper CLI service initialized with %s and model: %s", command, modelPath)
+
+	return &WhisperCLIService{
+		cfg:       cfg,
+		command:   command,
+		modelPath: modelPath,
+		run:       utils.RunCommand,
+	}, nil
+}
+
+// Transcribe converts 16-bit mono PCM audio to text
+func (w *WhisperCLIService) Transcribe(audioData []byte) (*models.TranscriptionResult, error) {
+	startTime := time.Now()
+	logger := utils.GetLogger()
+
+	if len(audioData) == 0 {
+		return nil, fmt.Errorf("empty audio data")
+	}
+
+	sampleRate := w.cfg.Audio.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = whisperSampleRate
+	}
+	if limit := 2 * w.cfg.STT.MaxDurationSeconds * sampleRate; limit > 0 && len(audioData) > limit {
+		logger.Warnf("Audio is %.1fs long, transcribing only the first %ds",
+			float64(len(audioData))/float64(2*sampleRate), w.cfg.STT.MaxDurationSeconds)
+		audioData = audioData[:limit]
+	}
+
+	dir, err := os.MkdirTemp("", "deeprecall-stt-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "audio.wav")
+	if err := os.WriteFile(input, wavFile(audioData, sampleRate), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write audio: %w", err)
+	}
+
+	data, err := w.whisper(input, filepath.Join(dir, "transcript"))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := parseWhisperCLIOutput(data)
+	if err != nil {
+		return nil, err
+	}
+	if result.Language == "" && w.cfg.STT.Language != "auto" {
+		result.Language = w.cfg.STT.Language
+	}
+	result.Duration = time.Since(startTime)
+
+	return result, nil
+}
+
+// whisper transcribes a WAV file and returns the JSON it writes to output.
+// With a build that rejects -ojf it falls back to -oj, which has no token
+// probabilities, and keeps using that.
+func (w *WhisperCLIService) whisper(input, output string) ([]byte, error) {
+	if !w.plainJSON.Load() {
+		data, err := w.runWhisper(input, output, "-ojf") // JSON with per-token probabilities
+		if err == nil {
+			return data, nil
+		}
+
+		data, plainErr := w.runWhisper(input, output, "-oj")
+		if plainErr != nil {
+			return nil, err
+		}
+		utils.GetLogger().Warnf("%s does not support -ojf, transcribing without confidence: %v", w.command, err)
+		w.plainJSON.Store(true)
+		return data, nil
+	}
+
+	return w.runWhisper(input, output, "-oj")
+}
+
+// runWhisper runs the binary with the given JSON output flag
+func (w *WhisperCLIService) runWhisper(input, output, jsonFlag string) ([]byte, error) {
+	args := []string{
+		"-m", w.modelPath,
+		"-f", input,
+		"-of", output,
+		jsonFlag,
+		"-np", // results only, no progress or system info
+	}
+	if language := w.cfg.STT.Language; language != "" {
+		args = append(args, "-l", language)
+	}
+	if w.cfg.STT.Threads > 0 {
+		args = append(args, "-t", strconv.Itoa(w.cfg.STT.Threads))
+	}
+	if w.cfg.STT.Translate {
+		args = append(args, "-tr")
+	}
+
+	// Old builds exit successfully on an unknown flag, so a missing output
+	// file is a failure too
+	os.Remove(output + ".json")
+	if _, err := w.run(context.Background(), w.command, args...); err != nil {
+		return nil, fmt.Errorf("whisper failed: %w", err)
+	}
+
+	data, err := os.ReadFile(output + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read whisper output: %w", err)
+	}
+	return data, nil
+}
+
+// TranscribeStream waits for stream to close and transcribes the audio once
+func (w *WhisperCLIService) TranscribeStream(stream <-chan []byte) (*models.TranscriptionResult, error) {
+	return w.Transcribe(collectAudio(stream))
+}
+
+// TranscribeLive transcribes audio as it arrives in a rolling window
+func (w *WhisperCLIService) TranscribeLive(ctx context.Context, stream <-chan []byte) <-chan StreamUpdate {
+	return newRollingTranscriber(w.cfg, w.Transcribe).run(ctx, stream)
+}
+
+// parseWhisperCLIOutput maps whisper-cli JSON onto a transcription result.
+// Confidence is the mean probability of text tokens, skipping control tokens
+// like [_BEG_] and [_TT_150].
+func parseWhisperCLIOutput(data []byte) (*models.TranscriptionResult, error) {
+	var out whisperCLIOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse whisper output: %w", err)
+	}
+
+	result := &models.TranscriptionResult{Language: out.Result.Language}
+
+	var text []string
+	var probSum float64
+	var probCount int
+	for _, entry := range out.Transcription {
+		segment := models.TranscriptSegment{
+			Start: time.Duration(entry.Offsets.From) * time.Millisecond,
+			End:   time.Duration(entry.Offsets.To) * time.Millisecond,
+			Text:  strings.TrimSpace(entry.Text),
+		}
+
+		var segSum float64
+		var segCount int
+		for _, token := range entry.Tokens {
+			if strings.HasPrefix(token.Text, "[_") || strings.HasPrefix(token.Text, "<|") {
+				continue
+			}
+			segSum += token.P
+			segCount++
+		}
+		if segCount > 0 {
+			segment.Confidence = segSum / float64(segCount)
+		}
+
+		result.Segments = append(result.Segments, segment)
+		text = append(text, segment.Text)
+		probSum += segSum
+		probCount += segCount
+	}
+
+	result.Text = strings.TrimSpace(strings.Join(text, " "))
+	if probCount > 0 {
+		result.Confidence = probSum / float64(probCount)
+	}
+
+	return result, nil
+}
+
+// wavFile wraps 16-bit mono PCM in a RIFF WAVE header
+func wavFile(pcm []byte, sampleRate int) []byte {
+	const channels, bits = 1, 16
+
+	out := make([]byte, 0, 44+len(pcm))
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(36+len(pcm)))
+	out = append(out, "WAVEfmt "...)
+	out = binary.LittleEndian.AppendUint32(out, 16)
+	out = binary.LittleEndian.AppendUint16(out, 1) // PCM
+	out = binary.LittleEndian.AppendUint16(out, channels)
+	out = binary.LittleEndian.AppendUint32(out, uint32(sampleRate))
+	out = binary.LittleEndian.AppendUint32(out, uint32(sampleRate*channels*bits/8))
+	out = binary.LittleEndian.AppendUint16(out, channels*bits/8)
+	out = binary.LittleEndian.AppendUint16(out, bits)
+	out = append(out, "data"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(pcm)))
+	return append(out, pcm...)
+}
@@ -0,0 +1,88 @@
+package stt
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shashwatssp/deeprecall/internal/config"
+)
+
+func newFakeWhisperCLI(t *testing.T, command string) *WhisperCLIService {
+	t.Helper()
+
+	command, err := filepath.Abs(filepath.Join("testdata", command))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelPath := filepath.Join(t.TempDir(), "ggml-test.bin")
+	if err := os.WriteFile(modelPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.STT.Command = command
+	cfg.STT.ModelPath = modelPath
+	cfg.STT.Language = "auto"
+	cfg.Audio.SampleRate = 16000
+
+	w, err := NewWhisperCLIService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestWhisperCLITranscribe(t *testing.T) {
+	tests := []struct {
+		command    string
+		confidence float64
+		segments   []float64 // segment confidences
+	}{
+		{command: "whisper-cli", confidence: 0.7, segments: []float64{0.8, 0.5}},
+		// Old builds without -ojf are retried with -oj, which has no tokens
+		{command: "main", confidence: 0, segments: []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			w := newFakeWhisperCLI(t, tt.command)
+
+			// Run twice so the fallback is also exercised once remembered
+			for i := 0; i < 2; i++ {
+				result, err := w.Transcribe(make([]byte, 3200))
+				if err != nil {
+					t.Fatalf("Transcribe: %v", err)
+				}
+
+				if result.Text != "Hello there world" {
+					t.Errorf("Text = %q, want %q", result.Text, "Hello there world")
+				}
+				if result.Language != "en" {
+					t.Errorf("Language = %q, want %q", result.Language, "en")
+				}
+				if math.Abs(result.Confidence-tt.confidence) > 1e-9 {
+					t.Errorf("Confidence = %v, want %v", result.Confidence, tt.confidence)
+				}
+
+				if len(result.Segments) != 2 {
+					t.Fatalf("got %d segments, want 2", len(result.Segments))
+				}
+				first, second := result.Segments[0], result.Segments[1]
+				if first.Text != "Hello there" || first.Start != 0 || first.End != 1500*time.Millisecond {
+					t.Errorf("first segment = %+v", first)
+				}
+				if second.Text != "world" || second.Start != 1500*time.Millisecond || second.End != 2200*time.Millisecond {
+					t.Errorf("second segment = %+v", second)
+				}
+				for j, segment := range result.Segments {
+					if math.Abs(segment.Confidence-tt.segments[j]) > 1e-9 {
+						t.Errorf("segment %d confidence = %v, want %v", j, segment.Confidence, tt.segments[j])
+					}
+				}
+			}
+		})
+	}
+}
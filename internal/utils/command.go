@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CommandRunner runs an external program and returns its standard output
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// RunCommand runs a program, including the end of its error output in the
+// error when it fails
+func RunCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err == nil {
+		return out, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			lines := strings.Split(msg, "\n")
+			return nil, fmt.Errorf("%w: %s", err, lines[len(lines)-1])
+		}
+	}
+	return nil, err
+}